@@ -178,3 +178,37 @@ func TestCacheGet(t *testing.T) {
 
 	testEngine.SetDefaultCacher(oldCacher)
 }
+
+func TestCacheFindAndCount(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type MailBox5 struct {
+		Id       int64
+		Username string
+	}
+
+	oldCacher := testEngine.GetDefaultCacher()
+	cacher := NewLRUCacher2(NewMemoryStore(), time.Hour, 10000)
+	testEngine.SetDefaultCacher(cacher)
+	defer testEngine.SetDefaultCacher(oldCacher)
+
+	assert.NoError(t, testEngine.Sync2(context.Background(), new(MailBox5)))
+
+	_, err := testEngine.Insert(context.Background(), []MailBox5{
+		{Username: "user1"},
+		{Username: "user2"},
+		{Username: "user3"},
+	})
+	assert.NoError(t, err)
+
+	// the beans are found by the ids of the cacher, then by the cache
+	for i := 0; i < 2; i++ {
+		var boxes []MailBox5
+		total, err := testEngine.Where("username <> ?", "user2").Asc("id").Limit(1).FindAndCount(context.Background(), &boxes)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 2, total)
+		if assert.EqualValues(t, 1, len(boxes)) {
+			assert.EqualValues(t, "user1", boxes[0].Username)
+		}
+	}
+}
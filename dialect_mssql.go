@@ -6,8 +6,10 @@ package xorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -336,10 +338,42 @@ func (db *mssql) TableCheckSql(tableName string) (string, []interface{}) {
 	return sql, args
 }
 
+// mssqlDefault strips the parentheses around the default value returned by
+// sql server, e.g. ((0)) or ('x'), so that it's the same as the default of the
+// struct
+func mssqlDefault(s string) string {
+	s = strings.TrimSpace(s)
+	for len(s) >= 2 && s[0] == '(' && mssqlClosingParen(s) == len(s)-1 {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// mssqlClosingParen returns the index of the parenthesis closing the first one
+func mssqlClosingParen(s string) int {
+	var quoted bool
+	var depth int
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
 func (db *mssql) GetColumns(ctx context.Context, tableName string) ([]string, map[string]*core.Column, error) {
 	args := []interface{}{}
 	s := `select a.name as name, b.name as ctype,a.max_length,a.precision,a.scale,a.is_nullable as nullable,
-	      replace(replace(isnull(c.text,''),'(',''),')','') as vdefault,
+	      isnull(c.text,'') as vdefault,
 		  ISNULL(i.is_primary_key, 0)
           from sys.columns a 
 		  left join sys.types b on a.user_type_id=b.user_type_id
@@ -372,7 +406,7 @@ func (db *mssql) GetColumns(ctx context.Context, tableName string) ([]string, ma
 		col.Indexes = make(map[string]int)
 		col.Name = strings.Trim(name, "` ")
 		col.Nullable = nullable
-		col.Default = vdefault
+		col.Default = mssqlDefault(vdefault)
 		col.IsPrimaryKey = isPK
 		ct := strings.ToUpper(ctype)
 		if ct == "DECIMAL" {
@@ -395,16 +429,6 @@ func (db *mssql) GetColumns(ctx context.Context, tableName string) ([]string, ma
 				return nil, nil, fmt.Errorf("Unknown colType %v for %v - %v", ct, tableName, col.Name)
 			}
 		}
-
-		if col.SQLType.IsText() || col.SQLType.IsTime() {
-			if col.Default != "" {
-				col.Default = "'" + col.Default + "'"
-			} else {
-				if col.DefaultIsEmpty {
-					col.Default = "''"
-				}
-			}
-		}
 		cols[col.Name] = col
 		colSeq = append(colSeq, col.Name)
 	}
@@ -499,6 +523,41 @@ WHERE IXS.TYPE_DESC='NONCLUSTERED' and OBJECT_NAME(IXS.OBJECT_ID) =?
 	return indexes, nil
 }
 
+func (db *mssql) GetForeignKeys(ctx context.Context, tableName string) ([]*ForeignKey, error) {
+	args := []interface{}{tableName}
+	s := `SELECT
+FK.NAME                              AS  [CONSTRAINT_NAME],
+C.NAME                               AS  [COLUMN_NAME],
+OBJECT_NAME(FKC.REFERENCED_OBJECT_ID) AS [REFERENCED_TABLE_NAME],
+RC.NAME                              AS  [REFERENCED_COLUMN_NAME],
+FK.DELETE_REFERENTIAL_ACTION_DESC    AS  [DELETE_RULE],
+FK.UPDATE_REFERENTIAL_ACTION_DESC    AS  [UPDATE_RULE]
+FROM SYS.FOREIGN_KEYS FK
+INNER JOIN SYS.FOREIGN_KEY_COLUMNS FKC ON FKC.CONSTRAINT_OBJECT_ID = FK.OBJECT_ID
+INNER JOIN SYS.COLUMNS C ON C.OBJECT_ID = FKC.PARENT_OBJECT_ID AND C.COLUMN_ID = FKC.PARENT_COLUMN_ID
+INNER JOIN SYS.COLUMNS RC ON RC.OBJECT_ID = FKC.REFERENCED_OBJECT_ID AND RC.COLUMN_ID = FKC.REFERENCED_COLUMN_ID
+WHERE OBJECT_NAME(FK.PARENT_OBJECT_ID) = ?
+ORDER BY FK.NAME, FKC.CONSTRAINT_COLUMN_ID
+`
+	db.LogSQL(ctx, s, args)
+
+	rows, err := db.DB().Query(ctx, s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fkRows foreignKeyRows
+	for rows.Next() {
+		var name, colName, refTable, refColName, onDelete, onUpdate string
+		if err = rows.Scan(&name, &colName, &refTable, &refColName, &onDelete, &onUpdate); err != nil {
+			return nil, err
+		}
+		fkRows.add(name, colName, refTable, refColName, onDelete, onUpdate)
+	}
+	return fkRows.fks, rows.Err()
+}
+
 func (db *mssql) CreateTableSql(table *core.Table, tableName, storeEngine, charset string) string {
 	var sql string
 	if tableName == "" {
@@ -541,6 +600,176 @@ func (db *mssql) Filters() []core.Filter {
 	return []core.Filter{&core.IdFilter{}, &core.QuoteFilter{}}
 }
 
+func (db *mssql) SupportIsolationLevel(level sql.IsolationLevel) bool {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSnapshot, sql.LevelSerializable:
+		return true
+	}
+	return false
+}
+
+func (db *mssql) SupportReadOnlyTx() bool {
+	return false
+}
+
+// SetTransactionSql returns the statement which sets the isolation level of
+// the connection, which is kept after the transaction
+func (db *mssql) SetTransactionSql(opts *sql.TxOptions) string {
+	return "SET TRANSACTION ISOLATION LEVEL " + strings.ToUpper(opts.Isolation.String())
+}
+
+// ResetTransactionSql returns the statement which restores the default
+// isolation level of the connection before the transaction ends
+func (db *mssql) ResetTransactionSql() string {
+	return "SET TRANSACTION ISOLATION LEVEL READ COMMITTED"
+}
+
+// MaxBindParams returns the max count of the parameters of a statement,
+// MSSQL allows at most 2100 parameters in a request
+func (db *mssql) MaxBindParams() int {
+	return 2100
+}
+
+// GeneratedColumnSql returns the definition of the computed column, the type
+// is derived from the expression and only a persisted column could be not
+// null
+func (db *mssql) GeneratedColumnSql(col *core.Column, gen *GeneratedColumn) string {
+	sql := db.Quote(col.Name) + " AS (" + gen.Expr + ")"
+	if gen.Stored {
+		sql += " PERSISTED"
+		if !col.Nullable {
+			sql += " NOT NULL"
+		}
+	}
+	return sql
+}
+
+// AlterColumnSqls returns the statements to change the type, default and
+// nullability of the column from oriCol to col. The default is a constraint
+// which has to be dropped before the column is altered.
+func (db *mssql) AlterColumnSqls(tableName string, col, oriCol *core.Column) []string {
+	var sqls []string
+	typeChanged := columnTypeChanged(db, col, oriCol) || columnNullableChanged(col, oriCol)
+	defaultChanged := columnDefaultChanged(col, oriCol)
+
+	if (typeChanged || defaultChanged) && oriCol.Default != "" {
+		sqls = append(sqls, db.dropDefaultSql(tableName, col.Name))
+	}
+	if typeChanged {
+		nullable := " NULL"
+		if !col.Nullable {
+			nullable = " NOT NULL"
+		}
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s%s",
+			db.Quote(tableName), db.Quote(col.Name), db.SqlType(col), nullable))
+	}
+	if (typeChanged || defaultChanged) && col.Default != "" {
+		sqls = append(sqls, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s DEFAULT %s FOR %s",
+			db.Quote(tableName), db.Quote("DF_"+tableName+"_"+col.Name), col.Default, db.Quote(col.Name)))
+	}
+	return sqls
+}
+
+// dropDefaultSql returns the statement to drop the default constraint of the
+// column whose name is generated by the server
+func (db *mssql) dropDefaultSql(tableName, colName string) string {
+	return fmt.Sprintf(`DECLARE @name SYSNAME;
+SELECT @name = DC.NAME FROM SYS.DEFAULT_CONSTRAINTS DC
+INNER JOIN SYS.COLUMNS C ON C.OBJECT_ID = DC.PARENT_OBJECT_ID AND C.COLUMN_ID = DC.PARENT_COLUMN_ID
+WHERE DC.PARENT_OBJECT_ID = OBJECT_ID('%s') AND C.NAME = '%s';
+IF @name IS NOT NULL EXEC('ALTER TABLE %s DROP CONSTRAINT [' + @name + ']')`,
+		tableName, colName, db.Quote(tableName))
+}
+
+// RenameColumnSql returns the statement to rename the column by sp_rename
+func (db *mssql) RenameColumnSql(tableName, oldName string, col *core.Column) string {
+	return fmt.Sprintf("EXEC sp_rename '%s.%s', '%s', 'COLUMN'", tableName, oldName, col.Name)
+}
+
+// DropColumnSqls returns the statements to drop the column, its default
+// constraint is dropped first
+func (db *mssql) DropColumnSqls(tableName, colName string) []string {
+	return []string{
+		db.dropDefaultSql(tableName, colName),
+		fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", db.Quote(tableName), db.Quote(colName)),
+	}
+}
+
+// mssqlError is implemented by the errors of github.com/denisenkom/go-mssqldb
+type mssqlError interface {
+	error
+	SQLErrorNumber() int32
+}
+
+func mssqlErrorNumber(err error) (int32, mssqlError, bool) {
+	var msErr mssqlError
+	if errors.As(err, &msErr) {
+		return msErr.SQLErrorNumber(), msErr, true
+	}
+	return 0, nil, false
+}
+
+func (db *mssql) IsRetryableError(err error) bool {
+	number, _, ok := mssqlErrorNumber(err)
+	// 1205 the transaction was deadlocked and has been chosen as the deadlock victim
+	return ok && number == 1205
+}
+
+var (
+	mssqlConstraintRegexp = regexp.MustCompile(`(?:constraint|index) '([^']+)'`)
+	mssqlObjectRegexp     = regexp.MustCompile(`(?:object|table) '(?:[^'.]+\.)*([^'.]+)'`)
+	mssqlColumnRegexp     = regexp.MustCompile(`column '([^']+)'`)
+)
+
+func (db *mssql) ClassifyError(err error) *DBError {
+	number, msErr, ok := mssqlErrorNumber(err)
+	if !ok {
+		return nil
+	}
+
+	var dbErr = DBError{Err: err}
+	switch number {
+	case 2627, 2601: // violation of unique key constraint, duplicate key row with unique index
+		dbErr.Kind = ErrUniqueViolation
+	case 547: // conflicted with the FOREIGN KEY (or CHECK) constraint
+		if !strings.Contains(msErr.Error(), "FOREIGN KEY") && !strings.Contains(msErr.Error(), "REFERENCE") {
+			return nil
+		}
+		dbErr.Kind = ErrForeignKeyViolation
+	case 515: // cannot insert the value NULL into column
+		dbErr.Kind = ErrNotNullViolation
+	case 1205:
+		dbErr.Kind = ErrDeadlock
+		return &dbErr
+	default:
+		return nil
+	}
+
+	msg := msErr.Error()
+	if matches := mssqlConstraintRegexp.FindStringSubmatch(msg); len(matches) == 2 {
+		dbErr.Constraint = matches[1]
+	}
+	if matches := mssqlObjectRegexp.FindStringSubmatch(msg); len(matches) == 2 {
+		dbErr.Table = matches[1]
+	}
+	if matches := mssqlColumnRegexp.FindStringSubmatch(msg); len(matches) == 2 {
+		dbErr.Column = matches[1]
+	}
+	return &dbErr
+}
+
+func (db *mssql) SavepointSQL(name string) string {
+	return "SAVE TRANSACTION " + name
+}
+
+func (db *mssql) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TRANSACTION " + name
+}
+
+func (db *mssql) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
 type odbcDriver struct {
 }
 
@@ -7,8 +7,10 @@ package xorm
 import (
 	"context"
 	"crypto/tls"
+	"database/sql"
 	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -488,10 +490,186 @@ func (db *mysql) GetIndexes(ctx context.Context, tableName string) (map[string]*
 	return indexes, nil
 }
 
+func (db *mysql) GetForeignKeys(ctx context.Context, tableName string) ([]*ForeignKey, error) {
+	args := []interface{}{db.DbName, tableName}
+	s := "SELECT k.`CONSTRAINT_NAME`, k.`COLUMN_NAME`, k.`REFERENCED_TABLE_NAME`, k.`REFERENCED_COLUMN_NAME`," +
+		" r.`DELETE_RULE`, r.`UPDATE_RULE` FROM `INFORMATION_SCHEMA`.`KEY_COLUMN_USAGE` k" +
+		" INNER JOIN `INFORMATION_SCHEMA`.`REFERENTIAL_CONSTRAINTS` r" +
+		" ON r.`CONSTRAINT_SCHEMA` = k.`TABLE_SCHEMA` AND r.`CONSTRAINT_NAME` = k.`CONSTRAINT_NAME`" +
+		" WHERE k.`TABLE_SCHEMA` = ? AND k.`TABLE_NAME` = ? AND k.`REFERENCED_TABLE_NAME` IS NOT NULL" +
+		" ORDER BY k.`CONSTRAINT_NAME`, k.`ORDINAL_POSITION`"
+	db.LogSQL(ctx, s, args)
+
+	rows, err := db.DB().Query(ctx, s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fkRows foreignKeyRows
+	for rows.Next() {
+		var name, colName, refTable, refColName, onDelete, onUpdate string
+		if err = rows.Scan(&name, &colName, &refTable, &refColName, &onDelete, &onUpdate); err != nil {
+			return nil, err
+		}
+		fkRows.add(name, colName, refTable, refColName, onDelete, onUpdate)
+	}
+	return fkRows.fks, rows.Err()
+}
+
 func (db *mysql) Filters() []core.Filter {
 	return []core.Filter{&core.IdFilter{}}
 }
 
+func (db *mysql) SupportIsolationLevel(level sql.IsolationLevel) bool {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable:
+		return true
+	}
+	return false
+}
+
+func (db *mysql) SupportReadOnlyTx() bool {
+	return true
+}
+
+// SetTransactionSql returns the statement which sets the options of the next
+// transaction, MySQL could not change them in a transaction
+func (db *mysql) SetTransactionSql(opts *sql.TxOptions) string {
+	var modes []string
+	if opts.Isolation != sql.LevelDefault {
+		modes = append(modes, "ISOLATION LEVEL "+strings.ToUpper(opts.Isolation.String()))
+	}
+	if opts.ReadOnly {
+		modes = append(modes, "READ ONLY")
+	}
+	return "SET TRANSACTION " + strings.Join(modes, ", ")
+}
+
+// MaxBindParams returns the max count of the parameters of a statement,
+// the count of the parameters is a 16-bit integer in the protocol
+func (db *mysql) MaxBindParams() int {
+	return 65535
+}
+
+// GeneratedColumnSql returns the definition of the generated column, which is
+// supported since MySQL 5.7
+func (db *mysql) GeneratedColumnSql(col *core.Column, gen *GeneratedColumn) string {
+	sql := db.Quote(col.Name) + " " + db.SqlType(col) + " GENERATED ALWAYS AS (" + gen.Expr + ")"
+	if gen.Stored {
+		sql += " STORED"
+	} else {
+		sql += " VIRTUAL"
+	}
+	if !col.Nullable {
+		sql += " NOT NULL"
+	}
+	return sql
+}
+
+// AlterColumnSqls returns the statements to change the type, default and
+// nullability of the column from oriCol to col, the whole definition of the
+// column is modified by one statement
+func (db *mysql) AlterColumnSqls(tableName string, col, oriCol *core.Column) []string {
+	return []string{fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s", db.Quote(tableName), db.columnDefinition(col))}
+}
+
+// RenameColumnSql returns the statement to rename the column, RENAME COLUMN
+// is not supported before MySQL 8.0 so the column is redefined by CHANGE
+func (db *mysql) RenameColumnSql(tableName, oldName string, col *core.Column) string {
+	return fmt.Sprintf("ALTER TABLE %s CHANGE %s %s", db.Quote(tableName), db.Quote(oldName), db.columnDefinition(col))
+}
+
+func (db *mysql) columnDefinition(col *core.Column) string {
+	sql := strings.TrimSpace(col.StringNoPk(db))
+	if col.IsAutoIncrement {
+		sql += " " + db.AutoIncrStr()
+	}
+	if len(col.Comment) > 0 {
+		sql += " COMMENT '" + col.Comment + "'"
+	}
+	return sql
+}
+
+var (
+	mysqlDupKeyRegexp     = regexp.MustCompile(`for key '([^']+)'`)
+	mysqlForeignKeyRegexp = regexp.MustCompile("\\(`[^`]+`\\.`([^`]+)`, CONSTRAINT `([^`]+)` FOREIGN KEY \\(`([^`]+)`\\)")
+	mysqlColumnRegexp     = regexp.MustCompile(`^(?:Column|Field) '([^']+)'`)
+)
+
+// mysqlNumberError is implemented by the errors which expose the server error
+// number of mysql
+type mysqlNumberError interface {
+	Number() uint16
+}
+
+// mysqlErrorNumber returns the server error number and message of a mysql
+// driver error. The *MySQLError of go-sql-driver/mysql is read by its Number
+// and Message fields, so that the driver isn't imported and registered.
+func mysqlErrorNumber(err error) (int, string, bool) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if numberErr, ok := err.(mysqlNumberError); ok {
+			return int(numberErr.Number()), err.Error(), true
+		}
+
+		v := reflect.ValueOf(err)
+		if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+			continue
+		}
+		number := v.Elem().FieldByName("Number")
+		message := v.Elem().FieldByName("Message")
+		if number.Kind() == reflect.Uint16 && message.Kind() == reflect.String {
+			return int(number.Uint()), message.String(), true
+		}
+	}
+	return 0, "", false
+}
+
+func (db *mysql) IsRetryableError(err error) bool {
+	number, _, ok := mysqlErrorNumber(err)
+	// 1213 ER_LOCK_DEADLOCK, 1205 ER_LOCK_WAIT_TIMEOUT
+	return ok && (number == 1213 || number == 1205)
+}
+
+func (db *mysql) ClassifyError(err error) *DBError {
+	number, msg, ok := mysqlErrorNumber(err)
+	if !ok {
+		return nil
+	}
+
+	switch number {
+	case 1062: // ER_DUP_ENTRY
+		dbErr := &DBError{Kind: ErrUniqueViolation, Err: err}
+		if matches := mysqlDupKeyRegexp.FindStringSubmatch(msg); len(matches) == 2 {
+			// mysql 8.0 reports the key as table.key
+			if idx := strings.LastIndex(matches[1], "."); idx > -1 {
+				dbErr.Table = matches[1][:idx]
+				dbErr.Constraint = matches[1][idx+1:]
+			} else {
+				dbErr.Constraint = matches[1]
+			}
+		}
+		return dbErr
+	case 1216, 1217, 1451, 1452: // ER_NO_REFERENCED_ROW, ER_ROW_IS_REFERENCED
+		dbErr := &DBError{Kind: ErrForeignKeyViolation, Err: err}
+		if matches := mysqlForeignKeyRegexp.FindStringSubmatch(msg); len(matches) == 4 {
+			dbErr.Table = matches[1]
+			dbErr.Constraint = matches[2]
+			dbErr.Column = matches[3]
+		}
+		return dbErr
+	case 1048, 1364: // ER_BAD_NULL_ERROR, ER_NO_DEFAULT_FOR_FIELD
+		dbErr := &DBError{Kind: ErrNotNullViolation, Err: err}
+		if matches := mysqlColumnRegexp.FindStringSubmatch(msg); len(matches) == 2 {
+			dbErr.Column = matches[1]
+		}
+		return dbErr
+	case 1213: // ER_LOCK_DEADLOCK
+		return &DBError{Kind: ErrDeadlock, Err: err}
+	}
+	return nil
+}
+
 type mymysqlDriver struct {
 }
 
@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"regexp"
@@ -851,10 +852,154 @@ func (db *oracle) GetIndexes(ctx context.Context, tableName string) (map[string]
 	return indexes, nil
 }
 
+// GetForeignKeys retrieves the foreign keys of the table, Oracle has no ON
+// UPDATE actions
+func (db *oracle) GetForeignKeys(ctx context.Context, tableName string) ([]*ForeignKey, error) {
+	args := []interface{}{tableName}
+	s := "SELECT c.constraint_name, cc.column_name, rc.table_name, rcc.column_name, c.delete_rule " +
+		"FROM user_constraints c, user_cons_columns cc, user_constraints rc, user_cons_columns rcc " +
+		"WHERE c.constraint_type = 'R' AND c.table_name = :1 " +
+		"AND cc.constraint_name = c.constraint_name AND cc.table_name = c.table_name " +
+		"AND rc.constraint_name = c.r_constraint_name " +
+		"AND rcc.constraint_name = rc.constraint_name AND rcc.position = cc.position " +
+		"ORDER BY c.constraint_name, cc.position"
+	db.LogSQL(ctx, s, args)
+
+	rows, err := db.DB().Query(ctx, s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fkRows foreignKeyRows
+	for rows.Next() {
+		var name, colName, refTable, refColName, onDelete string
+		if err = rows.Scan(&name, &colName, &refTable, &refColName, &onDelete); err != nil {
+			return nil, err
+		}
+		fkRows.add(name, colName, refTable, refColName, onDelete, "")
+	}
+	return fkRows.fks, rows.Err()
+}
+
 func (db *oracle) Filters() []core.Filter {
 	return []core.Filter{&core.QuoteFilter{}, &core.SeqFilter{Prefix: ":", Start: 1}, &core.IdFilter{}}
 }
 
+func (db *oracle) SupportIsolationLevel(level sql.IsolationLevel) bool {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadCommitted, sql.LevelSerializable:
+		return true
+	}
+	return false
+}
+
+func (db *oracle) SupportReadOnlyTx() bool {
+	return true
+}
+
+// MaxBindParams returns the max count of the parameters of a statement,
+// Oracle allows at most 65535 bind variables in a statement
+func (db *oracle) MaxBindParams() int {
+	return 65535
+}
+
+// GeneratedColumnSql returns the definition of the virtual column, Oracle
+// doesn't support stored generated columns
+func (db *oracle) GeneratedColumnSql(col *core.Column, gen *GeneratedColumn) string {
+	sql := db.Quote(col.Name) + " " + db.SqlType(col) + " GENERATED ALWAYS AS (" + gen.Expr + ") VIRTUAL"
+	if !col.Nullable {
+		sql += " NOT NULL"
+	}
+	return sql
+}
+
+// AlterColumnSqls returns the statements to change the type, default and
+// nullability of the column from oriCol to col, the nullability is only
+// modified when it's changed since Oracle refuses to set it again
+func (db *oracle) AlterColumnSqls(tableName string, col, oriCol *core.Column) []string {
+	var parts []string
+	if columnTypeChanged(db, col, oriCol) {
+		parts = append(parts, db.SqlType(col))
+	}
+	if columnDefaultChanged(col, oriCol) {
+		if col.Default == "" {
+			parts = append(parts, "DEFAULT NULL")
+		} else {
+			parts = append(parts, "DEFAULT "+col.Default)
+		}
+	}
+	if columnNullableChanged(col, oriCol) {
+		if col.Nullable {
+			parts = append(parts, "NULL")
+		} else {
+			parts = append(parts, "NOT NULL")
+		}
+	}
+	if len(parts) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %s MODIFY (%s %s)",
+		db.Quote(tableName), db.Quote(col.Name), strings.Join(parts, " "))}
+}
+
+func (db *oracle) SavepointSQL(name string) string {
+	return "SAVEPOINT " + name
+}
+
+func (db *oracle) RollbackToSavepointSQL(name string) string {
+	return "ROLLBACK TO SAVEPOINT " + name
+}
+
+func (db *oracle) ReleaseSavepointSQL(name string) string {
+	return ""
+}
+
+var (
+	oracleErrorRegexp      = regexp.MustCompile(`ORA-(\d{5}): (.*)`)
+	oracleConstraintRegexp = regexp.MustCompile(`constraint \((?:[^.)]+\.)?([^)]+)\)`)
+	oracleColumnRegexp     = regexp.MustCompile(`\("[^"]+"\."([^"]+)"\."([^"]+)"\)`)
+)
+
+func (db *oracle) IsRetryableError(err error) bool {
+	if matches := oracleErrorRegexp.FindStringSubmatch(err.Error()); len(matches) == 3 {
+		// ORA-00060 deadlock detected, ORA-08177 can't serialize access
+		return matches[1] == "00060" || matches[1] == "08177"
+	}
+	return false
+}
+
+func (db *oracle) ClassifyError(err error) *DBError {
+	matches := oracleErrorRegexp.FindStringSubmatch(err.Error())
+	if len(matches) != 3 {
+		return nil
+	}
+
+	var dbErr = DBError{Err: err}
+	switch matches[1] {
+	case "00001": // unique constraint violated
+		dbErr.Kind = ErrUniqueViolation
+	case "02291", "02292": // integrity constraint violated
+		dbErr.Kind = ErrForeignKeyViolation
+	case "01400", "01407": // cannot insert or update to NULL
+		dbErr.Kind = ErrNotNullViolation
+		if cols := oracleColumnRegexp.FindStringSubmatch(matches[2]); len(cols) == 3 {
+			dbErr.Table = cols[1]
+			dbErr.Column = cols[2]
+		}
+		return &dbErr
+	case "00060", "08177":
+		dbErr.Kind = ErrDeadlock
+		return &dbErr
+	default:
+		return nil
+	}
+	if cons := oracleConstraintRegexp.FindStringSubmatch(matches[2]); len(cons) == 2 {
+		dbErr.Constraint = cons[1]
+	}
+	return &dbErr
+}
+
 type goracleDriver struct {
 }
 
@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"net/url"
@@ -890,6 +891,39 @@ func (db *postgres) ModifyColumnSql(tableName string, col *core.Column) string {
 		tableName, col.Name, db.SqlType(col))
 }
 
+// AlterColumnSqls returns the statements to change the type, default and
+// nullability of the column from oriCol to col
+func (db *postgres) AlterColumnSqls(tableName string, col, oriCol *core.Column) []string {
+	var sqls []string
+	prefix := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", db.Quote(tableName), db.Quote(col.Name))
+	if columnTypeChanged(db, col, oriCol) {
+		sqlType := db.SqlType(col)
+		// the serial types are only allowed when creating tables
+		switch sqlType {
+		case core.Serial:
+			sqlType = core.Integer
+		case core.BigSerial:
+			sqlType = core.BigInt
+		}
+		sqls = append(sqls, prefix+fmt.Sprintf("TYPE %s USING %s::%s", sqlType, db.Quote(col.Name), sqlType))
+	}
+	if columnDefaultChanged(col, oriCol) {
+		if col.Default == "" {
+			sqls = append(sqls, prefix+"DROP DEFAULT")
+		} else {
+			sqls = append(sqls, prefix+"SET DEFAULT "+col.Default)
+		}
+	}
+	if columnNullableChanged(col, oriCol) {
+		if col.Nullable {
+			sqls = append(sqls, prefix+"DROP NOT NULL")
+		} else {
+			sqls = append(sqls, prefix+"SET NOT NULL")
+		}
+	}
+	return sqls
+}
+
 func (db *postgres) DropIndexSql(tableName string, index *core.Index) string {
 	//var unique string
 	quote := db.Quote
@@ -921,6 +955,28 @@ func (db *postgres) IsColumnExist(ctx context.Context, tableName, colName string
 	return rows.Next(), nil
 }
 
+// postgresDefault strips the type cast of the default value returned by
+// postgres, e.g. 'x'::character varying, so that it's the same as the default
+// of the struct
+func postgresDefault(s string) string {
+	var quoted bool
+	var depth int
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			quoted = !quoted
+		case quoted:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ':' && depth == 0 && strings.HasPrefix(s[i:], "::"):
+			return strings.TrimSpace(s[:i])
+		}
+	}
+	return s
+}
+
 func (db *postgres) GetColumns(ctx context.Context, tableName string) ([]string, map[string]*core.Column, error) {
 	// FIXME: the schema should be replaced by user custom's
 	args := []interface{}{tableName, "public"}
@@ -973,7 +1029,7 @@ WHERE c.relkind = 'r'::char AND c.relname = $1 AND s.table_schema = $2 AND f.att
 			if isPK {
 				col.IsPrimaryKey = true
 			} else {
-				col.Default = *colDefault
+				col.Default = postgresDefault(*colDefault)
 			}
 		}
 
@@ -1007,14 +1063,9 @@ WHERE c.relkind = 'r'::char AND c.relname = $1 AND s.table_schema = $2 AND f.att
 
 		col.Length = maxLen
 
-		if col.SQLType.IsText() || col.SQLType.IsTime() {
-			if col.Default != "" {
-				col.Default = "'" + col.Default + "'"
-			} else {
-				if col.DefaultIsEmpty {
-					col.Default = "''"
-				}
-			}
+		// the literals are quoted by postgres, and so are the negative numbers
+		if col.SQLType.IsNumeric() {
+			col.Default = strings.Trim(col.Default, "'")
 		}
 		cols[col.Name] = col
 		colSeq = append(colSeq, col.Name)
@@ -1100,10 +1151,142 @@ func (db *postgres) GetIndexes(ctx context.Context, tableName string) (map[strin
 	return indexes, nil
 }
 
+var postgresForeignKeyActions = map[string]string{
+	"a": "NO ACTION",
+	"r": "RESTRICT",
+	"c": "CASCADE",
+	"n": "SET NULL",
+	"d": "SET DEFAULT",
+}
+
+func (db *postgres) GetForeignKeys(ctx context.Context, tableName string) ([]*ForeignKey, error) {
+	// FIXME: replace the public schema to user specify schema
+	args := []interface{}{"public", tableName}
+	s := `SELECT con.conname, att.attname, ref.relname, refatt.attname, con.confdeltype, con.confupdtype
+FROM pg_constraint con
+INNER JOIN pg_class cls ON cls.oid = con.conrelid
+INNER JOIN pg_namespace ns ON ns.oid = cls.relnamespace
+INNER JOIN pg_class ref ON ref.oid = con.confrelid
+INNER JOIN LATERAL unnest(con.conkey, con.confkey) WITH ORDINALITY AS k(attnum, refattnum, pos) ON true
+INNER JOIN pg_attribute att ON att.attrelid = con.conrelid AND att.attnum = k.attnum
+INNER JOIN pg_attribute refatt ON refatt.attrelid = con.confrelid AND refatt.attnum = k.refattnum
+WHERE con.contype = 'f' AND ns.nspname = $1 AND cls.relname = $2
+ORDER BY con.conname, k.pos`
+	db.LogSQL(ctx, s, args)
+
+	rows, err := db.DB().Query(ctx, s, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fkRows foreignKeyRows
+	for rows.Next() {
+		var name, colName, refTable, refColName, onDelete, onUpdate string
+		if err = rows.Scan(&name, &colName, &refTable, &refColName, &onDelete, &onUpdate); err != nil {
+			return nil, err
+		}
+		fkRows.add(name, colName, refTable, refColName,
+			postgresForeignKeyActions[onDelete], postgresForeignKeyActions[onUpdate])
+	}
+	return fkRows.fks, rows.Err()
+}
+
 func (db *postgres) Filters() []core.Filter {
 	return []core.Filter{&core.IdFilter{}, &core.QuoteFilter{}, &core.SeqFilter{Prefix: "$", Start: 1}}
 }
 
+func (db *postgres) SupportIsolationLevel(level sql.IsolationLevel) bool {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelReadCommitted, sql.LevelRepeatableRead, sql.LevelSerializable:
+		return true
+	}
+	return false
+}
+
+func (db *postgres) SupportReadOnlyTx() bool {
+	return true
+}
+
+// SetTransactionSql returns the statement which sets the options of the
+// current transaction, it should be the first statement of the transaction
+func (db *postgres) SetTransactionSql(opts *sql.TxOptions) string {
+	var modes []string
+	if opts.Isolation != sql.LevelDefault {
+		modes = append(modes, "ISOLATION LEVEL "+strings.ToUpper(opts.Isolation.String()))
+	}
+	if opts.ReadOnly {
+		modes = append(modes, "READ ONLY")
+	}
+	return "SET TRANSACTION " + strings.Join(modes, ", ")
+}
+
+// MaxBindParams returns the max count of the parameters of a statement,
+// the count of the parameters is a 16-bit integer in the protocol
+func (db *postgres) MaxBindParams() int {
+	return 65535
+}
+
+// GeneratedColumnSql returns the definition of the generated column, which is
+// supported since Postgres 12. The column is always stored since Postgres
+// doesn't support virtual generated columns.
+func (db *postgres) GeneratedColumnSql(col *core.Column, gen *GeneratedColumn) string {
+	sql := db.Quote(col.Name) + " " + db.SqlType(col) + " GENERATED ALWAYS AS (" + gen.Expr + ") STORED"
+	if !col.Nullable {
+		sql += " NOT NULL"
+	}
+	return sql
+}
+
+// pqError is the legacy PGError interface which is implemented by *pq.Error
+type pqError interface {
+	error
+	Get(k byte) string
+}
+
+// postgresErrorCode returns the SQLSTATE code of a postgres driver error
+func postgresErrorCode(err error) (string, pqError, bool) {
+	var pqErr pqError
+	if errors.As(err, &pqErr) {
+		return pqErr.Get('C'), pqErr, true
+	}
+	return "", nil, false
+}
+
+func (db *postgres) IsRetryableError(err error) bool {
+	code, _, ok := postgresErrorCode(err)
+	// 40001 serialization_failure, 40P01 deadlock_detected
+	return ok && (code == "40001" || code == "40P01")
+}
+
+func (db *postgres) ClassifyError(err error) *DBError {
+	code, pqErr, ok := postgresErrorCode(err)
+	if !ok {
+		return nil
+	}
+
+	var kind error
+	switch code {
+	case "23505": // unique_violation
+		kind = ErrUniqueViolation
+	case "23503": // foreign_key_violation
+		kind = ErrForeignKeyViolation
+	case "23502": // not_null_violation
+		kind = ErrNotNullViolation
+	case "40001", "40P01": // serialization_failure, deadlock_detected
+		kind = ErrDeadlock
+	default:
+		return nil
+	}
+	return &DBError{
+		Kind:       kind,
+		Constraint: pqErr.Get('n'),
+		Table:      pqErr.Get('t'),
+		Column:     pqErr.Get('c'),
+		Err:        err,
+	}
+}
+
 type pqDriver struct {
 }
 
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/lingochamp/core"
@@ -445,10 +446,137 @@ func (db *sqlite3) GetIndexes(ctx context.Context, tableName string) (map[string
 	return indexes, nil
 }
 
+var sqliteForeignKeyNamePattern = regexp.MustCompile("(?i)CONSTRAINT\\s+([`\"\\[]?[^\\s`\"\\]]+[`\"\\]]?)\\s+FOREIGN\\s+KEY\\s*\\(([^)]*)\\)")
+
+// GetForeignKeys retrieves the foreign keys of the table, the pragma gives no
+// constraint names so they are parsed from the CREATE TABLE statement
+func (db *sqlite3) GetForeignKeys(ctx context.Context, tableName string) ([]*ForeignKey, error) {
+	args := []interface{}{tableName}
+	s := "SELECT sql FROM sqlite_master WHERE type='table' and name = ?"
+	db.LogSQL(ctx, s, args)
+
+	var createSQL sql.NullString
+	if err := db.DB().QueryRow(ctx, s, args...).Scan(&createSQL); err != nil {
+		return nil, err
+	}
+
+	var names = make(map[string]string)
+	for _, match := range sqliteForeignKeyNamePattern.FindAllStringSubmatch(createSQL.String, -1) {
+		var cols []string
+		for _, col := range strings.Split(match[2], ",") {
+			cols = append(cols, strings.ToLower(strings.Trim(col, "`\" []")))
+		}
+		names[strings.Join(cols, ",")] = strings.Trim(match[1], "`\"[]")
+	}
+
+	s = fmt.Sprintf("PRAGMA foreign_key_list(%s)", db.Quote(tableName))
+	db.LogSQL(ctx, s, nil)
+
+	rows, err := db.DB().Query(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fkRows foreignKeyRows
+	for rows.Next() {
+		var id, seq int
+		var refTable, colName, onUpdate, onDelete, match string
+		var refColName sql.NullString
+		if err = rows.Scan(&id, &seq, &refTable, &colName, &refColName, &onUpdate, &onDelete, &match); err != nil {
+			return nil, err
+		}
+		fkRows.add(strconv.Itoa(id), colName, refTable, refColName.String, onDelete, onUpdate)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, fk := range fkRows.fks {
+		fk.Name = names[strings.ToLower(strings.Join(fk.Columns, ","))]
+		if fk.Name == "" {
+			fk.Name = foreignKeyName(tableName, fk.Columns)
+		}
+	}
+	return fkRows.fks, nil
+}
+
 func (db *sqlite3) Filters() []core.Filter {
 	return []core.Filter{&core.IdFilter{}}
 }
 
+func (db *sqlite3) SupportIsolationLevel(level sql.IsolationLevel) bool {
+	switch level {
+	case sql.LevelDefault, sql.LevelReadUncommitted, sql.LevelSerializable:
+		return true
+	}
+	return false
+}
+
+func (db *sqlite3) SupportReadOnlyTx() bool {
+	return false
+}
+
+// MaxBindParams returns the max count of the parameters of a statement,
+// the default SQLITE_MAX_VARIABLE_NUMBER
+func (db *sqlite3) MaxBindParams() int {
+	return 999
+}
+
+// GeneratedColumnSql returns the definition of the generated column, which is
+// supported since SQLite 3.31
+func (db *sqlite3) GeneratedColumnSql(col *core.Column, gen *GeneratedColumn) string {
+	sql := db.Quote(col.Name) + " " + db.SqlType(col) + " GENERATED ALWAYS AS (" + gen.Expr + ")"
+	if gen.Stored {
+		sql += " STORED"
+	} else {
+		sql += " VIRTUAL"
+	}
+	if !col.Nullable {
+		sql += " NOT NULL"
+	}
+	return sql
+}
+
+var sqlite3ConstraintRegexp = regexp.MustCompile(`^(UNIQUE|NOT NULL|FOREIGN KEY) constraint failed(?:: ([^\s,]+))?`)
+
+func (db *sqlite3) ClassifyError(err error) *DBError {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		matches := sqlite3ConstraintRegexp.FindStringSubmatch(e.Error())
+		if len(matches) != 3 {
+			continue
+		}
+
+		var dbErr = DBError{Err: err}
+		switch matches[1] {
+		case "UNIQUE":
+			dbErr.Kind = ErrUniqueViolation
+		case "NOT NULL":
+			dbErr.Kind = ErrNotNullViolation
+		case "FOREIGN KEY":
+			dbErr.Kind = ErrForeignKeyViolation
+		}
+		// sqlite reports the first column as table.column
+		if fields := strings.SplitN(matches[2], ".", 2); len(fields) == 2 {
+			dbErr.Table = fields[0]
+			dbErr.Column = fields[1]
+		}
+		return &dbErr
+	}
+	return nil
+}
+
+func (db *sqlite3) IsRetryableError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		// SQLITE_BUSY and SQLITE_LOCKED
+		if msg := err.Error(); strings.HasPrefix(msg, "database is locked") ||
+			strings.HasPrefix(msg, "database table is locked") {
+			return true
+		}
+	}
+	return false
+}
+
 type sqlite3Driver struct {
 }
 
@@ -5,7 +5,6 @@
 package xorm
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"database/sql"
@@ -13,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"strconv"
@@ -38,6 +38,10 @@ type Engine struct {
 	mutex  *sync.RWMutex
 	Cacher core.Cacher
 
+	schemaMutex   *sync.RWMutex
+	structSchemas map[reflect.Type]*structSchema
+	dbForeignKeys map[string][]*ForeignKey
+
 	showSQL      bool
 	showExecTime bool
 
@@ -51,6 +55,13 @@ type Engine struct {
 
 	engineGroup *EngineGroup
 
+	txMaxRetries int
+	txBackoff    TxBackoff
+
+	// driverTxOptions caches if the driver supports the transaction options,
+	// 0 is unknown, 1 is supported and 2 is not
+	driverTxOptions int32
+
 	opts []Option
 }
 
@@ -145,6 +156,21 @@ func (engine *Engine) SupportInsertMany() bool {
 	return engine.dialect.SupportInsertMany()
 }
 
+// bindParamsDialect is implemented by the dialects which limit the count of
+// the parameters of a statement
+type bindParamsDialect interface {
+	MaxBindParams() int
+}
+
+// maxBindParams returns the max count of the parameters of a statement, 0
+// means unlimited
+func (engine *Engine) maxBindParams() int {
+	if dialect, ok := engine.dialect.(bindParamsDialect); ok {
+		return dialect.MaxBindParams()
+	}
+	return 0
+}
+
 // QuoteStr Engine's database use which character as quote.
 // mysql, sqlite use ` and postgres use "
 func (engine *Engine) QuoteStr() string {
@@ -280,6 +306,18 @@ func (engine *Engine) NewSession() *Session {
 	return session
 }
 
+// BeginTx creates a new session and begins a transaction on it with the
+// context and options. The caller should Commit or Rollback and then Close
+// the returned session.
+func (engine *Engine) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Session, error) {
+	session := engine.NewSession()
+	if err := session.BeginTx(ctx, opts); err != nil {
+		session.Close()
+		return nil, err
+	}
+	return session, nil
+}
+
 // Close the engine
 func (engine *Engine) Close() error {
 	return engine.db.Close()
@@ -340,12 +378,17 @@ func (engine *Engine) NoAutoCondition(no ...bool) *Session {
 }
 
 // DBMetas Retrieve all tables, columns, indexes' informations from database.
+// The foreign keys of the tables are retrieved too, use ForeignKeys to get
+// them.
 func (engine *Engine) DBMetas(ctx context.Context) ([]*core.Table, error) {
 	tables, err := engine.dialect.GetTables(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	fkDialect, hasForeignKeys := engine.dialect.(foreignKeyDialect)
+	var dbForeignKeys = make(map[string][]*ForeignKey, len(tables))
+
 	for _, table := range tables {
 		colSeq, cols, err := engine.dialect.GetColumns(ctx, table.Name)
 		if err != nil {
@@ -369,7 +412,19 @@ func (engine *Engine) DBMetas(ctx context.Context) ([]*core.Table, error) {
 				}
 			}
 		}
+
+		if hasForeignKeys {
+			fks, err := fkDialect.GetForeignKeys(ctx, table.Name)
+			if err != nil {
+				return nil, err
+			}
+			dbForeignKeys[table.Name] = fks
+		}
 	}
+
+	engine.schemaMutex.Lock()
+	engine.dbForeignKeys = dbForeignKeys
+	engine.schemaMutex.Unlock()
 	return tables, nil
 }
 
@@ -698,6 +753,20 @@ func (engine *Engine) NotIn(column string, args ...interface{}) *Session {
 	return session.NotIn(column, args...)
 }
 
+// Exists will generate "EXISTS (subquery)"
+func (engine *Engine) Exists(sub interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Exists(sub)
+}
+
+// NotExists will generate "NOT EXISTS (subquery)"
+func (engine *Engine) NotExists(sub interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.NotExists(sub)
+}
+
 // Incr provides a update string like "column = column + ?"
 func (engine *Engine) Incr(column string, arg ...interface{}) *Session {
 	session := engine.NewSession()
@@ -719,6 +788,27 @@ func (engine *Engine) SetExpr(column string, expression string) *Session {
 	return session.SetExpr(column, expression)
 }
 
+// OnConflict generates an upsert statement on insert, see Session.OnConflict
+func (engine *Engine) OnConflict(columns ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.OnConflict(columns...)
+}
+
+// Preload loads the relations of the found beans, see Session.Preload
+func (engine *Engine) Preload(relations ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Preload(relations...)
+}
+
+// Returning fills the bean with the affected record, see Session.Returning
+func (engine *Engine) Returning(columns ...string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.Returning(columns...)
+}
+
 // Table temporarily change the Get, Find, Update's table
 func (engine *Engine) Table(tableNameOrBean interface{}) *Session {
 	session := engine.NewSession()
@@ -726,6 +816,13 @@ func (engine *Engine) Table(tableNameOrBean interface{}) *Session {
 	return session.Table(tableNameOrBean)
 }
 
+// After sets the cursor of the page to be found by FindPage
+func (engine *Engine) After(cursor string) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.After(cursor)
+}
+
 // Alias set the table alias
 func (engine *Engine) Alias(alias string) *Session {
 	session := engine.NewSession()
@@ -733,6 +830,20 @@ func (engine *Engine) Alias(alias string) *Session {
 	return session.Alias(alias)
 }
 
+// With adds a common table expression to the query
+func (engine *Engine) With(name string, query interface{}, args ...interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.With(name, query, args...)
+}
+
+// WithRecursive adds a recursive common table expression to the query
+func (engine *Engine) WithRecursive(name string, query interface{}, args ...interface{}) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.WithRecursive(name, query, args...)
+}
+
 // Limit will generate "LIMIT start, limit"
 func (engine *Engine) Limit(limit int, start ...int) *Session {
 	session := engine.NewSession()
@@ -895,6 +1006,7 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 
 	var idFieldColName string
 	var hasCacheTag, hasNoCacheTag bool
+	var schema = newStructSchema()
 
 	for i := 0; i < t.NumField(); i++ {
 		tag := t.Field(i).Tag
@@ -926,6 +1038,7 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 					if err := ExtendsTagHandler(&ctx); err != nil {
 						return nil, err
 					}
+					schema.merge(ctx.extendedSchema)
 					continue
 				}
 
@@ -981,6 +1094,10 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 					}
 				}
 
+				if ctx.isRelation {
+					continue
+				}
+
 				if col.SQLType.Name == "" {
 					col.SQLType = core.Type2SQLType(fieldType)
 				}
@@ -995,6 +1112,23 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 					col.Name = engine.ColumnMapper.Obj2Table(t.Field(i).Name)
 				}
 
+				if ctx.foreignKey != nil {
+					if ctx.foreignKey.RefTable == "" {
+						return nil, fmt.Errorf("%v.%s has on_delete or on_update tag but no fk tag", t, col.FieldName)
+					}
+					ctx.foreignKey.Columns = []string{col.Name}
+					schema.foreignKeys = append(schema.foreignKeys, ctx.foreignKey)
+				}
+				if ctx.check != "" {
+					schema.checks = append(schema.checks, &CheckConstraint{Expr: ctx.check, column: col.Name})
+				}
+				if ctx.generated != nil {
+					schema.generated[col.Name] = ctx.generated
+				}
+				if ctx.renamedFrom != "" {
+					schema.renames[col.Name] = ctx.renamedFrom
+				}
+
 				if ctx.isUnique {
 					ctx.indexNames[col.Name] = core.UniqueType
 				} else if ctx.isIndex {
@@ -1056,6 +1190,8 @@ func (engine *Engine) mapType(v reflect.Value) (*core.Table, error) {
 		table.Cacher = nil
 	}
 
+	engine.setStructSchema(t, schema)
+
 	return table, nil
 }
 
@@ -1317,6 +1453,31 @@ func (engine *Engine) Sync2(ctx context.Context, beans ...interface{}) error {
 	return s.Sync2(ctx, beans...)
 }
 
+// DryRun writes the statements to w instead of executing them, it's used to
+// review the statements of Sync2 before changing the database
+func (engine *Engine) DryRun(w io.Writer) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.DryRun(w)
+}
+
+// DropUnused makes Sync2 drop the columns which are not declared by the
+// structs and SchemaDiff report them, the columns with data are dropped only
+// if force is true
+func (engine *Engine) DropUnused(force bool) *Session {
+	session := engine.NewSession()
+	session.isAutoClose = true
+	return session.DropUnused(force)
+}
+
+// SchemaDiff compares the structs with their tables in the database and
+// returns the changes to make the database the same as the structs
+func (engine *Engine) SchemaDiff(ctx context.Context, beans ...interface{}) (*SchemaDiff, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.SchemaDiff(ctx, beans...)
+}
+
 // CreateTables create tabls according bean
 func (engine *Engine) CreateTables(ctx context.Context, beans ...interface{}) error {
 	session := engine.NewSession()
@@ -1334,6 +1495,25 @@ func (engine *Engine) CreateTables(ctx context.Context, beans ...interface{}) er
 			return err
 		}
 	}
+
+	// the foreign keys are added after all the tables are created, so the
+	// tables could refer to each other, sqlite declares them when creating
+	if engine.dialect.DBType() != core.SQLITE {
+		for _, bean := range beans {
+			v := rValue(bean)
+			table, err := engine.autoMapType(v)
+			if err != nil {
+				session.Rollback()
+				return err
+			}
+			tableName := engine.tbName(v)
+			err = session.addForeignKeys(ctx, tableName, engine.tableForeignKeys(table, tableName), nil)
+			if err != nil {
+				session.Rollback()
+				return err
+			}
+		}
+	}
 	return session.Commit()
 }
 
@@ -1399,6 +1579,13 @@ func (engine *Engine) Insert(ctx context.Context, beans ...interface{}) (int64,
 	return session.Insert(ctx, beans...)
 }
 
+// BulkInsert inserts a large number of records, see Session.BulkInsert
+func (engine *Engine) BulkInsert(ctx context.Context, beans interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.BulkInsert(ctx, beans)
+}
+
 // InsertOne insert only one record
 func (engine *Engine) InsertOne(ctx context.Context, bean interface{}) (int64, error) {
 	session := engine.NewSession()
@@ -1449,6 +1636,20 @@ func (engine *Engine) Find(ctx context.Context, beans interface{}, condiBeans ..
 	return session.Find(ctx, beans, condiBeans...)
 }
 
+// FindAndCount finds the records and returns the total count of the records
+func (engine *Engine) FindAndCount(ctx context.Context, beans interface{}, condiBeans ...interface{}) (int64, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.FindAndCount(ctx, beans, condiBeans...)
+}
+
+// FindPage finds a page of the rows by keyset pagination
+func (engine *Engine) FindPage(ctx context.Context, beans interface{}, condiBeans ...interface{}) (*Page, error) {
+	session := engine.NewSession()
+	defer session.Close()
+	return session.FindPage(ctx, beans, condiBeans...)
+}
+
 // Iterate record by record handle records from table, bean's non-empty fields
 // are conditions.
 func (engine *Engine) Iterate(ctx context.Context, bean interface{}, fun IterFunc) error {
@@ -1509,42 +1710,23 @@ func (engine *Engine) ImportFile(ctx context.Context, ddlPath string) ([]sql.Res
 	return engine.Import(ctx, file)
 }
 
-// Import SQL DDL from io.Reader
+// Import SQL DDL from io.Reader, the statements are split by SplitSQL
 func (engine *Engine) Import(ctx context.Context, r io.Reader) ([]sql.Result, error) {
-	var results []sql.Result
-	var lastError error
-	scanner := bufio.NewScanner(r)
-
-	semiColSpliter := func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		if atEOF && len(data) == 0 {
-			return 0, nil, nil
-		}
-		if i := bytes.IndexByte(data, ';'); i >= 0 {
-			return i + 1, data[0:i], nil
-		}
-		// If we're at EOF, we have a final, non-terminated line. Return it.
-		if atEOF {
-			return len(data), data, nil
-		}
-		// Request more data.
-		return 0, nil, nil
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
 	}
 
-	scanner.Split(semiColSpliter)
-
-	for scanner.Scan() {
-		query := strings.Trim(scanner.Text(), " \t\n\r")
-		if len(query) > 0 {
-			engine.logSQL(ctx, query)
-			result, err := engine.DB().Exec(ctx, query)
-			results = append(results, result)
-			if err != nil {
-				return nil, err
-			}
+	var results []sql.Result
+	for _, query := range SplitSQL(engine.dialect.DBType(), string(data)) {
+		engine.logSQL(ctx, query)
+		result, err := engine.DB().Exec(ctx, query)
+		results = append(results, result)
+		if err != nil {
+			return nil, err
 		}
 	}
-
-	return results, lastError
+	return results, nil
 }
 
 // nowTime return current time
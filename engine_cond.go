@@ -8,7 +8,6 @@ import (
 	"context"
 	"database/sql/driver"
 	"encoding/json"
-	"fmt"
 	"reflect"
 	"time"
 
@@ -174,8 +173,7 @@ func (engine *Engine) buildConds(table *core.Table, bean interface{},
 								continue
 							}
 						} else {
-							//TODO: how to handler?
-							return nil, fmt.Errorf("not supported %v as %v", fieldValue.Interface(), table.PrimaryKeys)
+							return nil, ErrCompositeCascade
 						}
 					} else {
 						val = fieldValue.Interface()
@@ -95,6 +95,12 @@ func DefaultCacherOption(cacher core.Cacher) Option {
 	}
 }
 
+func TxRetryOption(maxRetries int, backoff TxBackoff) Option {
+	return func(x *Engine) {
+		x.SetTxRetry(maxRetries, backoff)
+	}
+}
+
 func defaultOptions() []Option {
 	logger := NewSimpleLogger(os.Stdout)
 	logger.SetLevel(core.LOG_INFO)
@@ -0,0 +1,118 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// retryableErrorDialect is implemented by the dialects which could tell
+// whether an error is caused by a deadlock or a serialization failure, so
+// that the whole transaction could be retried.
+type retryableErrorDialect interface {
+	IsRetryableError(err error) bool
+}
+
+// TxBackoff returns the duration to wait before the attempt-th retry of a
+// transaction, attempt starts from 1.
+type TxBackoff func(attempt int) time.Duration
+
+// ExponentialBackoff returns a TxBackoff which doubles the wait duration on
+// every retry, starting from base and never exceeding max.
+func ExponentialBackoff(base, max time.Duration) TxBackoff {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		return d
+	}
+}
+
+// SetTxRetry sets how many times Transaction retries when the transaction
+// failed because of a deadlock or a serialization failure, and how long to
+// wait between the retries. maxRetries 0 disables retrying.
+func (engine *Engine) SetTxRetry(maxRetries int, backoff TxBackoff) {
+	engine.txMaxRetries = maxRetries
+	engine.txBackoff = backoff
+}
+
+func (engine *Engine) isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if dialect, ok := engine.dialect.(retryableErrorDialect); ok {
+		return dialect.IsRetryableError(err)
+	}
+	return false
+}
+
+// Transaction executes f in a transaction. The transaction is committed if
+// f returns nil, or rolled back if f returns an error or panics. If the
+// transaction failed because of a deadlock or a serialization failure, it
+// will be retried according to SetTxRetry.
+//
+//	err := engine.Transaction(ctx, func(session *xorm.Session) error {
+//	    if _, err := session.Insert(ctx, &user); err != nil {
+//	        return err
+//	    }
+//	    _, err := session.ID(user.Id).Update(ctx, &other)
+//	    return err
+//	})
+func (engine *Engine) Transaction(ctx context.Context, f func(*Session) error) error {
+	return engine.TransactionTx(ctx, nil, f)
+}
+
+// TransactionTx executes f in a transaction started with opts, see Transaction
+func (engine *Engine) TransactionTx(ctx context.Context, opts *sql.TxOptions, f func(*Session) error) error {
+	for attempt := 0; ; attempt++ {
+		err := engine.transaction(ctx, opts, f)
+		if err == nil || attempt >= engine.txMaxRetries || !engine.isRetryableError(err) {
+			return err
+		}
+
+		var wait time.Duration
+		if engine.txBackoff != nil {
+			wait = engine.txBackoff(attempt + 1)
+		}
+		engine.logger(ctx).Warnf("transaction failed: %v, retry %d after %v", err, attempt+1, wait)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (engine *Engine) transaction(ctx context.Context, opts *sql.TxOptions, f func(*Session) error) (err error) {
+	session := engine.NewSession()
+	defer session.Close()
+
+	if err = session.BeginTx(ctx, opts); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			session.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = f(session); err != nil {
+		if rbErr := session.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w, rollback failed: %v", err, rbErr)
+		}
+		return err
+	}
+	return session.Commit()
+}
@@ -0,0 +1,121 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Millisecond, 50*time.Millisecond)
+	assert.EqualValues(t, 10*time.Millisecond, backoff(1))
+	assert.EqualValues(t, 20*time.Millisecond, backoff(2))
+	assert.EqualValues(t, 40*time.Millisecond, backoff(3))
+	assert.EqualValues(t, 50*time.Millisecond, backoff(4))
+	assert.EqualValues(t, 50*time.Millisecond, backoff(10))
+}
+
+func TestEngineTransaction(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(Userinfo))
+
+	ctx := context.Background()
+
+	err := testEngine.Transaction(ctx, func(session *Session) error {
+		_, err := session.Insert(ctx, &Userinfo{Username: "tx_commit"})
+		return err
+	})
+	assert.NoError(t, err)
+
+	errRollback := errors.New("rollback")
+	err = testEngine.Transaction(ctx, func(session *Session) error {
+		if _, err := session.Insert(ctx, &Userinfo{Username: "tx_rollback"}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.Equal(t, errRollback, err)
+
+	assert.Panics(t, func() {
+		testEngine.Transaction(ctx, func(session *Session) error {
+			if _, err := session.Insert(ctx, &Userinfo{Username: "tx_panic"}); err != nil {
+				return err
+			}
+			panic("tx panic")
+		})
+	})
+
+	for name, exist := range map[string]bool{"tx_commit": true, "tx_rollback": false, "tx_panic": false} {
+		has, err := testEngine.Exist(ctx, &Userinfo{Username: name})
+		assert.NoError(t, err)
+		assert.EqualValues(t, exist, has, name)
+	}
+}
+
+func TestEngineTransactionRetry(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	if _, ok := testEngine.Dialect().(retryableErrorDialect); !ok {
+		t.Skip("dialect could not classify retryable errors")
+	}
+	defer testEngine.SetTxRetry(0, nil)
+
+	retryable := map[string]error{
+		"mysql":    &testMySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"},
+		"postgres": &testPGError{code: "40001"},
+		"sqlite3":  errors.New("database is locked"),
+		"mssql":    &testMSSQLError{number: 1205},
+	}[string(testEngine.Dialect().DBType())]
+	if retryable == nil {
+		t.Skip("no retryable error for this dialect")
+	}
+
+	var times int
+	testEngine.SetTxRetry(2, ExponentialBackoff(time.Millisecond, 10*time.Millisecond))
+	err := testEngine.Transaction(context.Background(), func(session *Session) error {
+		times++
+		if times < 3 {
+			return retryable
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, times)
+
+	times = 0
+	testEngine.SetTxRetry(1, nil)
+	err = testEngine.Transaction(context.Background(), func(session *Session) error {
+		times++
+		return retryable
+	})
+	assert.Equal(t, retryable, err)
+	assert.EqualValues(t, 2, times)
+}
+
+type testPGError struct {
+	code string
+}
+
+func (e *testPGError) Error() string { return "pq: " + e.code }
+
+func (e *testPGError) Get(k byte) string {
+	if k == 'C' {
+		return e.code
+	}
+	return ""
+}
+
+type testMSSQLError struct {
+	number int32
+}
+
+func (e *testMSSQLError) Error() string { return "mssql: error" }
+
+func (e *testMSSQLError) SQLErrorNumber() int32 { return e.number }
@@ -25,4 +25,82 @@ var (
 	ErrNotImplemented = errors.New("Not implemented")
 	// ErrConditionType condition type unsupported
 	ErrConditionType = errors.New("Unsupported conditon type")
+	// ErrNotInTransaction savepoint operations need a transaction
+	ErrNotInTransaction = errors.New("Not in transaction")
+	// ErrReturningUnsupported the dialect could not return the affected rows
+	ErrReturningUnsupported = errors.New("Returning is not supported")
+	// ErrReturningRows more than one row is returned into a bean
+	ErrReturningRows = errors.New("Returning more than one row into a bean")
+	// ErrColumnNotEmpty the column to be dropped has data
+	ErrColumnNotEmpty = errors.New("Column is not empty")
+	// ErrOrderNotUnique keyset pagination needs the order on a unique key
+	ErrOrderNotUnique = errors.New("Order is not on a unique key")
+	// ErrInvalidCursor the cursor of the page could not be decoded
+	ErrInvalidCursor = errors.New("Invalid cursor")
+	// ErrTooManyParams the parameters exceed the max of the dialect
+	ErrTooManyParams = errors.New("Too many parameters")
+	// ErrCompositeCascade a belongs-to field refers to a table of composite
+	// primary keys, which could not be stored in one column
+	ErrCompositeCascade = errors.New("Composite cascade keys are not supported")
 )
+
+var (
+	// ErrUniqueViolation a unique constraint or unique index is violated
+	ErrUniqueViolation = errors.New("Unique constraint violation")
+	// ErrForeignKeyViolation a foreign key constraint is violated
+	ErrForeignKeyViolation = errors.New("Foreign key constraint violation")
+	// ErrNotNullViolation a NULL value is inserted into a NOT NULL column
+	ErrNotNullViolation = errors.New("Not null constraint violation")
+	// ErrDeadlock the transaction is aborted because of a deadlock or a
+	// serialization failure and could be retried
+	ErrDeadlock = errors.New("Deadlock or serialization failure")
+)
+
+// DBError wraps an error returned by the database driver with its kind, which
+// is one of ErrUniqueViolation, ErrForeignKeyViolation, ErrNotNullViolation
+// and ErrDeadlock. errors.Is(err, ErrUniqueViolation) reports whether err is
+// of the kind, errors.As(err, &dbErr) gives the constraint details, and the
+// driver error is still reachable through errors.As.
+type DBError struct {
+	Kind       error
+	Constraint string
+	Table      string
+	Column     string
+	Err        error
+}
+
+func (e *DBError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the driver error
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the kind target
+func (e *DBError) Is(target error) bool {
+	return e.Kind == target
+}
+
+// errorClassifierDialect is implemented by the dialects which could map the
+// errors of their drivers to DBError, it returns nil if the error is unknown.
+type errorClassifierDialect interface {
+	ClassifyError(err error) *DBError
+}
+
+func (engine *Engine) classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var dbErr *DBError
+	if errors.As(err, &dbErr) {
+		return err
+	}
+	if dialect, ok := engine.dialect.(errorClassifierDialect); ok {
+		if dbErr = dialect.ClassifyError(err); dbErr != nil {
+			return dbErr
+		}
+	}
+	return err
+}
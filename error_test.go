@@ -0,0 +1,93 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testPQError struct {
+	fields map[byte]string
+}
+
+func (e *testPQError) Error() string { return "pq: " + e.fields['M'] }
+
+func (e *testPQError) Get(k byte) string { return e.fields[k] }
+
+// testMySQLError has the fields of the *MySQLError of go-sql-driver/mysql
+type testMySQLError struct {
+	Number   uint16
+	SQLState [5]byte
+	Message  string
+}
+
+func (e *testMySQLError) Error() string { return fmt.Sprintf("Error %d: %s", e.Number, e.Message) }
+
+func TestClassifyError(t *testing.T) {
+	var cases = []struct {
+		dialect    errorClassifierDialect
+		err        error
+		kind       error
+		constraint string
+		table      string
+		column     string
+	}{
+		{&mysql{}, &testMySQLError{Number: 1062, Message: "Duplicate entry 'lunny' for key 'UQE_user_name'"}, ErrUniqueViolation, "UQE_user_name", "", ""},
+		{&mysql{}, &testMySQLError{Number: 1062, Message: "Duplicate entry 'lunny' for key 'user.UQE_user_name'"}, ErrUniqueViolation, "UQE_user_name", "user", ""},
+		{&mysql{}, &testMySQLError{Number: 1062, Message: "Duplicate entry 'multi\nline' for key 'UQE_user_name'"}, ErrUniqueViolation, "UQE_user_name", "", ""},
+		{&mysql{}, &testMySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`test`.`order`, CONSTRAINT `fk_order_user` FOREIGN KEY (`user_id`) REFERENCES `user` (`id`))"}, ErrForeignKeyViolation, "fk_order_user", "order", "user_id"},
+		{&mysql{}, &testMySQLError{Number: 1048, Message: "Column 'name' cannot be null"}, ErrNotNullViolation, "", "", "name"},
+		{&mysql{}, &testMySQLError{Number: 1213, Message: "Deadlock found when trying to get lock; try restarting transaction"}, ErrDeadlock, "", "", ""},
+		{&postgres{}, &testPQError{map[byte]string{'C': "23505", 'n': "UQE_user_name", 't': "user"}}, ErrUniqueViolation, "UQE_user_name", "user", ""},
+		{&postgres{}, &testPQError{map[byte]string{'C': "23502", 't': "user", 'c': "name"}}, ErrNotNullViolation, "", "user", "name"},
+		{&postgres{}, &testPQError{map[byte]string{'C': "40P01"}}, ErrDeadlock, "", "", ""},
+		{&sqlite3{}, errors.New("UNIQUE constraint failed: user.name"), ErrUniqueViolation, "", "user", "name"},
+		{&sqlite3{}, errors.New("FOREIGN KEY constraint failed"), ErrForeignKeyViolation, "", "", ""},
+		{&mssql{}, &testMSSQLError{number: 1205}, ErrDeadlock, "", "", ""},
+		{&oracle{}, errors.New("ORA-00001: unique constraint (XORM.UQE_USER_NAME) violated"), ErrUniqueViolation, "UQE_USER_NAME", "", ""},
+		{&oracle{}, errors.New(`ORA-01400: cannot insert NULL into ("XORM"."USER"."NAME")`), ErrNotNullViolation, "", "USER", "NAME"},
+	}
+
+	for _, c := range cases {
+		dbErr := c.dialect.ClassifyError(fmt.Errorf("exec failed: %w", c.err))
+		if !assert.NotNil(t, dbErr, c.err.Error()) {
+			continue
+		}
+		assert.True(t, errors.Is(dbErr, c.kind), c.err.Error())
+		assert.True(t, errors.Is(dbErr, c.err), c.err.Error())
+		assert.EqualValues(t, c.constraint, dbErr.Constraint, c.err.Error())
+		assert.EqualValues(t, c.table, dbErr.Table, c.err.Error())
+		assert.EqualValues(t, c.column, dbErr.Column, c.err.Error())
+	}
+
+	assert.Nil(t, (&mysql{}).ClassifyError(&testMySQLError{Number: 1146, Message: "Table 'test.user' doesn't exist"}))
+	assert.Nil(t, (&sqlite3{}).ClassifyError(errors.New("no such table: user")))
+}
+
+type UniqueViolation struct {
+	Id   int64
+	Name string `xorm:"unique"`
+}
+
+func TestUniqueViolationError(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(UniqueViolation))
+
+	_, err := testEngine.Insert(context.Background(), &UniqueViolation{Name: "lunny"})
+	assert.NoError(t, err)
+
+	_, err = testEngine.Insert(context.Background(), &UniqueViolation{Name: "lunny"})
+	assert.Error(t, err)
+	assert.True(t, errors.Is(err, ErrUniqueViolation))
+
+	var dbErr *DBError
+	assert.True(t, errors.As(err, &dbErr))
+	assert.False(t, errors.Is(err, ErrForeignKeyViolation))
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lingochamp/core"
+)
+
+// ForeignKey represents a FOREIGN KEY constraint of a table
+type ForeignKey struct {
+	Name       string
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+	OnDelete   string
+	OnUpdate   string
+}
+
+var foreignKeyActions = map[string]bool{
+	"CASCADE":     true,
+	"RESTRICT":    true,
+	"NO ACTION":   true,
+	"SET NULL":    true,
+	"SET DEFAULT": true,
+}
+
+// foreignKeyAction normalizes the referential action, the words of the action
+// in tags are separated by underscores, e.g. on_delete(set_null)
+func foreignKeyAction(action string) (string, error) {
+	action = strings.ToUpper(strings.TrimSpace(strings.Replace(action, "_", " ", -1)))
+	if !foreignKeyActions[action] {
+		return "", fmt.Errorf("unknown referential action %s", action)
+	}
+	return action, nil
+}
+
+func foreignKeyName(tableName string, cols []string) string {
+	return fmt.Sprintf("FK_%v_%v", tableName, strings.Join(cols, "_"))
+}
+
+func equalFoldStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// sameAction returns true if the actions are the same, no action is the
+// default action of the databases
+func sameAction(a, b string) bool {
+	if a == "" {
+		a = "NO ACTION"
+	}
+	if b == "" {
+		b = "NO ACTION"
+	}
+	return a == b
+}
+
+// Equal returns true if the foreign keys have the same columns, references
+// and actions, the names are not compared
+func (fk *ForeignKey) Equal(dst *ForeignKey) bool {
+	return equalFoldStrings(fk.Columns, dst.Columns) &&
+		strings.EqualFold(fk.RefTable, dst.RefTable) &&
+		equalFoldStrings(fk.RefColumns, dst.RefColumns) &&
+		sameAction(fk.OnDelete, dst.OnDelete) &&
+		sameAction(fk.OnUpdate, dst.OnUpdate)
+}
+
+// foreignKeyDialect is implemented by the dialects which could retrieve the
+// foreign keys of a table
+type foreignKeyDialect interface {
+	GetForeignKeys(ctx context.Context, tableName string) ([]*ForeignKey, error)
+}
+
+// dbForeignKeyAction normalizes the referential action retrieved from the
+// database, e.g. SET_NULL of MSSQL
+func dbForeignKeyAction(action string) string {
+	return strings.ToUpper(strings.TrimSpace(strings.Replace(action, "_", " ", -1)))
+}
+
+// foreignKeyRows groups the columns retrieved from the database into the
+// foreign keys by the constraint names
+type foreignKeyRows struct {
+	fks    []*ForeignKey
+	byName map[string]*ForeignKey
+}
+
+func (rows *foreignKeyRows) add(name, col, refTable, refCol, onDelete, onUpdate string) {
+	if rows.byName == nil {
+		rows.byName = make(map[string]*ForeignKey)
+	}
+	fk, ok := rows.byName[name]
+	if !ok {
+		fk = &ForeignKey{
+			Name:     name,
+			RefTable: refTable,
+			OnDelete: dbForeignKeyAction(onDelete),
+			OnUpdate: dbForeignKeyAction(onUpdate),
+		}
+		rows.byName[name] = fk
+		rows.fks = append(rows.fks, fk)
+	}
+	fk.Columns = append(fk.Columns, col)
+	fk.RefColumns = append(fk.RefColumns, refCol)
+}
+
+// ForeignKeys returns the foreign keys of the table, the table is mapped from
+// a struct or retrieved by DBMetas
+func (engine *Engine) ForeignKeys(table *core.Table) []*ForeignKey {
+	return engine.tableForeignKeys(table, table.Name)
+}
+
+// tableForeignKeys returns the copies of the foreign keys of the table, the
+// foreign keys of a struct are named by the table name if the name is empty
+func (engine *Engine) tableForeignKeys(table *core.Table, tableName string) []*ForeignKey {
+	var fks []*ForeignKey
+	if table.Type != nil {
+		fks = engine.structSchemaOf(table.Type).foreignKeys
+	} else {
+		engine.schemaMutex.RLock()
+		fks = engine.dbForeignKeys[table.Name]
+		engine.schemaMutex.RUnlock()
+	}
+
+	var res = make([]*ForeignKey, 0, len(fks))
+	for _, fk := range fks {
+		fkCopy := *fk
+		if fkCopy.Name == "" {
+			fkCopy.Name = foreignKeyName(tableName, fk.Columns)
+		}
+		res = append(res, &fkCopy)
+	}
+	return res
+}
+
+// foreignKeyClause returns the constraint clause of the foreign key, Oracle
+// has no ON UPDATE and supports ON DELETE CASCADE and SET NULL only
+func (engine *Engine) foreignKeyClause(fk *ForeignKey) string {
+	quote := engine.Quote
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "CONSTRAINT %v FOREIGN KEY (%v) REFERENCES %v (%v)",
+		quote(fk.Name),
+		quote(strings.Join(fk.Columns, quote(","))),
+		quote(fk.RefTable),
+		quote(strings.Join(fk.RefColumns, quote(","))))
+
+	var isOracle = engine.dialect.DBType() == core.ORACLE
+	if fk.OnDelete != "" && (!isOracle || fk.OnDelete == "CASCADE" || fk.OnDelete == "SET NULL") {
+		buf.WriteString(" ON DELETE " + fk.OnDelete)
+	}
+	if fk.OnUpdate != "" && !isOracle {
+		buf.WriteString(" ON UPDATE " + fk.OnUpdate)
+	}
+	return buf.String()
+}
+
+func (engine *Engine) addForeignKeySQL(tableName string, fk *ForeignKey) string {
+	return fmt.Sprintf("ALTER TABLE %v ADD %v", engine.Quote(tableName), engine.foreignKeyClause(fk))
+}
+
+func (engine *Engine) dropForeignKeySQL(tableName string, fk *ForeignKey) string {
+	if engine.dialect.DBType() == core.MYSQL {
+		return fmt.Sprintf("ALTER TABLE %v DROP FOREIGN KEY %v", engine.Quote(tableName), engine.Quote(fk.Name))
+	}
+	return fmt.Sprintf("ALTER TABLE %v DROP CONSTRAINT %v", engine.Quote(tableName), engine.Quote(fk.Name))
+}
@@ -100,11 +100,17 @@ func splitTag(tag string) (tags []string) {
 	tag = strings.TrimSpace(tag)
 	var hasQuote = false
 	var lastIdx = 0
+	// the parameters in the parentheses may have spaces, e.g. check(age >= 0)
+	var depth = 0
 	for i, t := range tag {
 		if t == '\'' {
 			hasQuote = !hasQuote
+		} else if t == '(' && !hasQuote {
+			depth++
+		} else if t == ')' && !hasQuote && depth > 0 {
+			depth--
 		} else if t == ' ' {
-			if lastIdx < i && !hasQuote {
+			if lastIdx < i && !hasQuote && depth == 0 {
 				tags = append(tags, strings.TrimSpace(tag[lastIdx:i]))
 				lastIdx = i + 1
 			}
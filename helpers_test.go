@@ -15,6 +15,8 @@ func TestSplitTag(t *testing.T) {
 		{"TEXT", []string{"TEXT"}},
 		{"default('2000-01-01 00:00:00')", []string{"default('2000-01-01 00:00:00')"}},
 		{"json  binary", []string{"json", "binary"}},
+		{"check(age >= 0) generated(price * (1 - discount)) stored", []string{"check(age >= 0)", "generated(price * (1 - discount))", "stored"}},
+		{"comment('a (b') index", []string{"comment('a (b')", "index"}},
 	}
 
 	for _, kase := range cases {
@@ -7,6 +7,7 @@ package xorm
 import (
 	"context"
 	"database/sql"
+	"io"
 	"reflect"
 	"time"
 
@@ -16,9 +17,11 @@ import (
 // Interface defines the interface which Engine, EngineGroup and Session will implementate.
 type Interface interface {
 	AllCols() *Session
+	After(cursor string) *Session
 	Alias(alias string) *Session
 	Asc(colNames ...string) *Session
 	BufferSize(size int) *Session
+	BulkInsert(ctx context.Context, beans interface{}) (int64, error)
 	Cols(columns ...string) *Session
 	Count(context.Context, ...interface{}) (int64, error)
 	CreateIndexes(ctx context.Context, bean interface{}) error
@@ -28,9 +31,14 @@ type Interface interface {
 	Delete(context.Context, interface{}) (int64, error)
 	Distinct(columns ...string) *Session
 	DropIndexes(ctx context.Context, bean interface{}) error
+	DropUnused(force bool) *Session
+	DryRun(w io.Writer) *Session
 	Exec(context.Context, string, ...interface{}) (sql.Result, error)
 	Exist(ctx context.Context, bean ...interface{}) (bool, error)
+	Exists(sub interface{}) *Session
 	Find(context.Context, interface{}, ...interface{}) error
+	FindAndCount(context.Context, interface{}, ...interface{}) (int64, error)
+	FindPage(context.Context, interface{}, ...interface{}) (*Page, error)
 	Get(context.Context, interface{}) (bool, error)
 	GroupBy(keys string) *Session
 	ID(interface{}) *Session
@@ -43,14 +51,18 @@ type Interface interface {
 	Iterate(context.Context, interface{}, IterFunc) error
 	Limit(int, ...int) *Session
 	NoAutoCondition(...bool) *Session
+	NotExists(sub interface{}) *Session
 	NotIn(string, ...interface{}) *Session
+	OnConflict(columns ...string) *Session
 	Join(joinOperator string, tablename interface{}, condition string, args ...interface{}) *Session
 	Omit(columns ...string) *Session
 	OrderBy(order string) *Session
+	Preload(relations ...string) *Session
 	Ping(ctx context.Context) error
 	Query(ctx context.Context, sqlOrAgrs ...interface{}) (resultsSlice []map[string][]byte, err error)
 	QueryInterface(ctx context.Context, sqlorArgs ...interface{}) ([]map[string]interface{}, error)
 	QueryString(ctx context.Context, sqlorArgs ...interface{}) ([]map[string]string, error)
+	Returning(columns ...string) *Session
 	Rows(ctx context.Context, bean interface{}) (*Rows, error)
 	SetExpr(string, string) *Session
 	SQL(interface{}, ...interface{}) *Session
@@ -63,6 +75,8 @@ type Interface interface {
 	Update(ctx context.Context, bean interface{}, condiBeans ...interface{}) (int64, error)
 	UseBool(...string) *Session
 	Where(interface{}, ...interface{}) *Session
+	With(name string, query interface{}, args ...interface{}) *Session
+	WithRecursive(name string, query interface{}, args ...interface{}) *Session
 }
 
 // EngineInterface defines the interface which Engine, EngineGroup will implementate.
@@ -71,11 +85,14 @@ type EngineInterface interface {
 
 	Before(func(interface{})) *Session
 	Charset(charset string) *Session
+	Checks(*core.Table) []*CheckConstraint
 	CreateTables(context.Context, ...interface{}) error
 	DBMetas(context.Context) ([]*core.Table, error)
 	Dialect() core.Dialect
 	DropTables(context.Context, ...interface{}) error
 	DumpAllToFile(ctx context.Context, fp string, tp ...core.DbType) error
+	ForeignKeys(*core.Table) []*ForeignKey
+	GeneratedColumn(table *core.Table, colName string) *GeneratedColumn
 	GetColumnMapper() core.IMapper
 	GetDefaultCacher() core.Cacher
 	GetTableMapper() core.IMapper
@@ -84,16 +101,21 @@ type EngineInterface interface {
 	NewSession() *Session
 	NoAutoTime() *Session
 	Quote(string) string
+	RenamedFrom(table *core.Table, colName string) string
+	SchemaDiff(context.Context, ...interface{}) (*SchemaDiff, error)
 	SetDefaultCacher(core.Cacher)
 	SetLogLevel(core.LogLevel)
 	SetMapper(core.IMapper)
 	SetTZDatabase(tz *time.Location)
 	SetTZLocation(tz *time.Location)
+	SetTxRetry(maxRetries int, backoff TxBackoff)
 	ShowSQL(show ...bool)
 	Sync(context.Context, ...interface{}) error
 	Sync2(context.Context, ...interface{}) error
 	StoreEngine(storeEngine string) *Session
 	TableInfo(bean interface{}) *Table
+	Transaction(ctx context.Context, f func(*Session) error) error
+	TransactionTx(ctx context.Context, opts *sql.TxOptions, f func(*Session) error) error
 	UnMapType(reflect.Type)
 }
 
@@ -0,0 +1,266 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/lingochamp/core"
+)
+
+var (
+	// DefaultLockTimeout is used when Options.LockTimeout is not set.
+	DefaultLockTimeout = time.Minute
+
+	// ErrLockTimeout is returned when the migration lock is held by another
+	// runner until the timeout.
+	ErrLockTimeout = errors.New("Could not acquire the migration lock")
+
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+func (m *Migrate) lockTimeout() time.Duration {
+	if m.options.LockTimeout > 0 {
+		return m.options.LockTimeout
+	}
+	return DefaultLockTimeout
+}
+
+func (m *Migrate) lockName() string {
+	return "xorm_migrate_" + m.options.TableName
+}
+
+// withLock runs f while holding the migration lock, so that only one runner
+// migrates the database at a time. Postgres uses an advisory lock, MySQL uses
+// GET_LOCK, MSSQL uses sp_getapplock and the other databases use a row of the
+// lock table, which is released as stale after Options.LockTTL or by
+// ForceUnlock if a runner crashed with it.
+func (m *Migrate) withLock(ctx context.Context, f func() error) error {
+	if m.options.DisableLock {
+		return f()
+	}
+
+	var unlock func() error
+	var err error
+	switch m.db.Dialect().DBType() {
+	case core.POSTGRES, core.MYSQL, core.MSSQL:
+		unlock, err = m.lockConn(ctx)
+	default:
+		unlock, err = m.lockRow(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = f()
+	if unlockErr := unlock(); err == nil {
+		err = unlockErr
+	}
+	return err
+}
+
+// pollLock calls try until it acquires the lock or the timeout
+func pollLock(ctx context.Context, timeout time.Duration, try func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := try()
+		if err != nil || ok {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrLockTimeout
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func quoteString(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+// lockConn acquires the session level lock of the database, the lock is
+// bound to the connection so the connection is kept until it's unlocked
+func (m *Migrate) lockConn(ctx context.Context) (func() error, error) {
+	conn, err := m.db.DB().Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var name = m.lockName()
+	var timeout = m.lockTimeout()
+	var unlockSQL string
+	switch m.db.Dialect().DBType() {
+	case core.POSTGRES:
+		h := fnv.New64a()
+		h.Write([]byte(name))
+		key := int64(h.Sum64())
+		err = pollLock(ctx, timeout, func() (bool, error) {
+			var ok bool
+			err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT pg_try_advisory_lock(%d)", key)).Scan(&ok)
+			return ok, err
+		})
+		unlockSQL = fmt.Sprintf("SELECT pg_advisory_unlock(%d)", key)
+	case core.MYSQL:
+		var res sql.NullInt64
+		seconds := int64((timeout + time.Second - 1) / time.Second)
+		err = conn.QueryRowContext(ctx, fmt.Sprintf("SELECT GET_LOCK(%s, %d)", quoteString(name), seconds)).Scan(&res)
+		if err == nil && res.Int64 != 1 {
+			err = ErrLockTimeout
+		}
+		unlockSQL = fmt.Sprintf("SELECT RELEASE_LOCK(%s)", quoteString(name))
+	case core.MSSQL:
+		var res int
+		err = conn.QueryRowContext(ctx, fmt.Sprintf(`DECLARE @res INT;
+EXEC @res = sp_getapplock @Resource = %s, @LockMode = 'Exclusive', @LockOwner = 'Session', @LockTimeout = %d;
+SELECT @res`, quoteString(name), int64(timeout/time.Millisecond))).Scan(&res)
+		if err == nil && res < 0 {
+			err = ErrLockTimeout
+		}
+		unlockSQL = fmt.Sprintf("EXEC sp_releaseapplock @Resource = %s, @LockOwner = 'Session'", quoteString(name))
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return func() error {
+		defer conn.Close()
+		// the lock should be released even if the context is canceled
+		_, err := conn.ExecContext(context.Background(), unlockSQL)
+		return err
+	}, nil
+}
+
+func (m *Migrate) lockTableName() string {
+	return m.options.TableName + "_lock"
+}
+
+// lockedAtLayout is the layout of the time when the lock row is inserted, the
+// former versions wrote it by RFC3339
+const lockedAtLayout = "2006-01-02 15:04:05.000000"
+
+func parseLockedAt(s string) (time.Time, bool) {
+	for _, layout := range []string{lockedAtLayout, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// lockHolder identifies the runner holding the lock row
+func lockHolder() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}
+
+func (m *Migrate) createLockTableIfNotExists(ctx context.Context) error {
+	exists, err := m.db.IsTableExist(ctx, m.lockTableName())
+	if err != nil {
+		return err
+	}
+	if exists {
+		// the lock tables of the former versions have no holder
+		if exists, err = m.db.Dialect().IsColumnExist(ctx, m.lockTableName(), "locked_by"); err != nil || exists {
+			return err
+		}
+		sql := fmt.Sprintf("ALTER TABLE %s ADD locked_by VARCHAR(255)", m.lockTableName())
+		if _, err := m.db.Exec(ctx, sql); err != nil {
+			// another runner may have added it
+			if exists, _ := m.db.Dialect().IsColumnExist(ctx, m.lockTableName(), "locked_by"); !exists {
+				return err
+			}
+		}
+		return nil
+	}
+
+	sql := fmt.Sprintf("CREATE TABLE %s (%s VARCHAR(255) PRIMARY KEY, locked_at VARCHAR(64), locked_by VARCHAR(255))",
+		m.lockTableName(), m.options.IDColumnName)
+	if _, err := m.db.Exec(ctx, sql); err != nil {
+		// another runner may have created it
+		if exists, _ := m.db.IsTableExist(ctx, m.lockTableName()); !exists {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockRow acquires the lock by inserting the row of the lock name into the
+// lock table, the primary key makes sure only one runner inserts it. The row
+// records the holder and the time, it's deleted as stale if it's held longer
+// than Options.LockTTL, e.g. the runner holding it crashed.
+func (m *Migrate) lockRow(ctx context.Context) (func() error, error) {
+	if err := m.createLockTableIfNotExists(ctx); err != nil {
+		return nil, err
+	}
+
+	var name = m.lockName()
+	var holder = lockHolder()
+	var lockedAt string
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s, locked_at, locked_by) VALUES (?, ?, ?)", m.lockTableName(), m.options.IDColumnName)
+	selectSQL := fmt.Sprintf("SELECT locked_at, locked_by FROM %s WHERE %s = ?", m.lockTableName(), m.options.IDColumnName)
+	deleteSQL := fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND locked_at = ?", m.lockTableName(), m.options.IDColumnName)
+	err := pollLock(ctx, m.lockTimeout(), func() (bool, error) {
+		lockedAt = time.Now().UTC().Format(lockedAtLayout)
+		_, err := m.db.Exec(ctx, insertSQL, name, lockedAt, holder)
+		if err == nil {
+			return true, nil
+		}
+
+		// the insert fails because the row is held by another runner
+		rows, queryErr := m.db.QueryString(ctx, selectSQL, name)
+		if queryErr != nil || len(rows) == 0 {
+			return false, err
+		}
+		if t, ok := parseLockedAt(rows[0]["locked_at"]); ok && m.options.LockTTL > 0 && time.Since(t) > m.options.LockTTL {
+			// the row is deleted only if it isn't taken by another runner
+			// in the meantime
+			m.db.Logger()(ctx).Warnf("the migration lock held by %s since %s is stale, releasing it",
+				rows[0]["locked_by"], rows[0]["locked_at"])
+			if _, err := m.db.Exec(ctx, deleteSQL, name, rows[0]["locked_at"]); err != nil {
+				return false, err
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return func() error {
+		// the row isn't deleted if it's released as stale and taken by
+		// another runner
+		sql := fmt.Sprintf("DELETE FROM %s WHERE %s = ? AND locked_at = ? AND locked_by = ?", m.lockTableName(), m.options.IDColumnName)
+		_, err := m.db.Exec(context.Background(), sql, name, lockedAt, holder)
+		return err
+	}, nil
+}
+
+// ForceUnlock releases the migration lock held by another runner, e.g. the
+// one which crashed with the lock row of the databases without session locks.
+// The session locks of Postgres, MySQL and MSSQL are released by the
+// databases when the connections are closed, so it does nothing for them.
+func (m *Migrate) ForceUnlock(ctx context.Context) error {
+	switch m.db.Dialect().DBType() {
+	case core.POSTGRES, core.MYSQL, core.MSSQL:
+		return nil
+	}
+
+	exists, err := m.db.IsTableExist(ctx, m.lockTableName())
+	if err != nil || !exists {
+		return err
+	}
+	sql := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", m.lockTableName(), m.options.IDColumnName)
+	_, err = m.db.Exec(ctx, sql, m.lockName())
+	return err
+}
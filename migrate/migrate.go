@@ -2,20 +2,31 @@ package migrate
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/go-xorm/xorm"
+	"github.com/lingochamp/core"
 )
 
-// MigrateFunc is the func signature for migrating.
-type MigrateFunc func(*xorm.Engine) error
+// MigrateFunc is the func signature for migrating. The session is in the
+// transaction of the migration if the dialect supports transactional DDL.
+// Sync2 and SchemaDiff read the tables by DBMetas on another connection, so
+// they don't see the tables changed by the transaction, and they could block
+// on the locks of the transaction; use them in a migration with
+// NoTransaction, or change the tables by the SQL of the session.
+type MigrateFunc func(context.Context, *xorm.Session) error
 
 // RollbackFunc is the func signature for rollbacking.
-type RollbackFunc func(*xorm.Engine) error
+type RollbackFunc func(context.Context, *xorm.Session) error
 
 // InitSchemaFunc is the func signature for initializing the schema.
-type InitSchemaFunc func(*xorm.Engine) error
+type InitSchemaFunc func(context.Context, *xorm.Session) error
 
 // Options define options for all migrations.
 type Options struct {
@@ -23,16 +34,51 @@ type Options struct {
 	TableName string
 	// IDColumnName is the name of column where the migration id will be stored.
 	IDColumnName string
+	// LockTimeout is how long to wait for the migration lock held by another
+	// runner, DefaultLockTimeout is used if it's 0.
+	LockTimeout time.Duration
+	// LockTTL is how long the lock row of the databases without session
+	// locks is held before it's released as stale, e.g. the runner holding
+	// it crashed. It should be longer than the migrations, and the lock row
+	// never expires if it's 0.
+	LockTTL time.Duration
+	// DisableLock runs the migrations without the migration lock.
+	DisableLock bool
+	// WarnChecksumMismatch logs the applied migrations which have been
+	// changed instead of returning ErrChecksumMismatch.
+	WarnChecksumMismatch bool
 }
 
 // Migration represents a database migration (a modification to be made on the database).
 type Migration struct {
 	// ID is the migration identifier. Usually a timestamp like "201601021504".
 	ID string
+	// Name describes the migration, it's the name of the SQL files.
+	Name string
 	// Migrate is a function that will br executed while running this migration.
 	Migrate MigrateFunc
 	// Rollback will be executed on rollback. Can be nil.
 	Rollback RollbackFunc
+	// NoTransaction runs the migration without a transaction, it's needed by
+	// the statements which can't run in a transaction, e.g. CREATE INDEX
+	// CONCURRENTLY of Postgres.
+	NoTransaction bool
+	// Version is recorded as the checksum of a Go migration, it should be
+	// changed with the Migrate func so that the change is detected.
+	Version string
+
+	// the statements of the up file of a SQL migration
+	upSQL string
+}
+
+// checksum returns the checksum recorded with the migration, it's the SHA-256
+// of the up file of a SQL migration or the Version of a Go migration
+func (mig *Migration) checksum() string {
+	if mig.upSQL == "" {
+		return mig.Version
+	}
+	sum := sha256.Sum256([]byte(strings.Replace(mig.upSQL, "\r\n", "\n", -1)))
+	return hex.EncodeToString(sum[:])
 }
 
 // Migrate represents a collection of all migrations of a database schema.
@@ -63,14 +109,36 @@ var (
 	// ErrNoRunnedMigration is returned when any runned migration was found while
 	// running RollbackLast
 	ErrNoRunnedMigration = errors.New("Could not find last runned migration")
+
+	// ErrUnknownID is returned when the target of MigrateTo or RollbackTo is
+	// not defined
+	ErrUnknownID = errors.New("Unknown migration ID")
+
+	// ErrChecksumMismatch is returned when an applied migration has been
+	// changed, Repair records the checksums of the changed migrations
+	ErrChecksumMismatch = errors.New("Applied migration has been changed")
+
+	// ErrNotInitialized is returned by Status when the migration table
+	// doesn't exist, i.e. no migration has run
+	ErrNotInitialized = errors.New("Migration table is not initialized")
 )
 
-// New returns a new Gormigrate.
+// New returns a new Gormigrate. The migrations are ordered by their IDs, so
+// the migrations of Go funcs and SQL files could be mixed, e.g.
+//
+//	files, err := migrate.LoadDir("migrations")
+//	m := migrate.New(db, migrate.DefaultOptions, append(migrations, files...))
 func New(db *xorm.Engine, options *Options, migrations []*Migration) *Migrate {
+	var sorted = make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return lessID(sorted[i].ID, sorted[j].ID)
+	})
+
 	return &Migrate{
 		db:         db,
 		options:    options,
-		migrations: migrations,
+		migrations: sorted,
 	}
 }
 
@@ -82,11 +150,21 @@ func (m *Migrate) InitSchema(initSchema InitSchemaFunc) {
 	m.initSchema = initSchema
 }
 
-// Migrate executes all migrations that did not run yet.
+// Migrate executes all migrations that did not run yet. The runners of the
+// same database wait for each other by the migration lock.
 func (m *Migrate) Migrate(ctx context.Context) error {
+	return m.withLock(ctx, func() error {
+		return m.migrate(ctx)
+	})
+}
+
+func (m *Migrate) migrate(ctx context.Context) error {
 	if err := m.createMigrationTableIfNotExists(ctx); err != nil {
 		return err
 	}
+	if err := m.verifyChecksums(ctx); err != nil {
+		return err
+	}
 
 	if m.initSchema != nil && m.isFirstRun(ctx) {
 		if err := m.runInitSchema(ctx); err != nil {
@@ -109,15 +187,14 @@ func (m *Migrate) RollbackLast(ctx context.Context) error {
 		return ErrNoMigrationDefined
 	}
 
-	lastRunnedMigration, err := m.getLastRunnedMigration(ctx)
-	if err != nil {
-		return err
-	}
+	return m.withLock(ctx, func() error {
+		lastRunnedMigration, err := m.getLastRunnedMigration(ctx)
+		if err != nil {
+			return err
+		}
 
-	if err := m.RollbackMigration(ctx, lastRunnedMigration); err != nil {
-		return err
-	}
-	return nil
+		return m.RollbackMigration(ctx, lastRunnedMigration)
+	})
 }
 
 func (m *Migrate) getLastRunnedMigration(ctx context.Context) (*Migration, error) {
@@ -136,29 +213,55 @@ func (m *Migrate) RollbackMigration(ctx context.Context, mig *Migration) error {
 		return ErrRollbackImpossible
 	}
 
-	if err := mig.Rollback(m.db); err != nil {
-		return err
-	}
+	return m.withSession(ctx, !mig.NoTransaction, func(session *xorm.Session) error {
+		if err := mig.Rollback(ctx, session); err != nil {
+			return err
+		}
 
-	sql := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", m.options.TableName, m.options.IDColumnName)
-	if _, err := m.db.Exec(ctx, sql, mig.ID); err != nil {
-		return err
-	}
-	return nil
+		sql := fmt.Sprintf("DELETE FROM %s WHERE %s = ?", m.options.TableName, m.options.IDColumnName)
+		if _, err := session.Exec(ctx, sql, mig.ID); err != nil {
+			return err
+		}
+		return nil
+	})
 }
 
 func (m *Migrate) runInitSchema(ctx context.Context) error {
-	if err := m.initSchema(m.db); err != nil {
-		return err
-	}
-
-	for _, migration := range m.migrations {
-		if err := m.insertMigration(ctx, migration.ID); err != nil {
+	return m.withSession(ctx, true, func(session *xorm.Session) error {
+		if err := m.initSchema(ctx, session); err != nil {
 			return err
 		}
+
+		for _, migration := range m.migrations {
+			if err := m.insertMigration(ctx, session, migration, 0); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// transactionalDDL returns true if the DDL statements of the dialect could be
+// rolled back, MySQL and Oracle commit the transaction implicitly before them
+func (m *Migrate) transactionalDDL() bool {
+	switch m.db.Dialect().DBType() {
+	case core.POSTGRES, core.MSSQL, core.SQLITE:
+		return true
+	}
+	return false
+}
+
+// withSession runs f with a session, f and the record of the migration are
+// committed or rolled back together if useTx is true and the dialect supports
+// transactional DDL
+func (m *Migrate) withSession(ctx context.Context, useTx bool, f func(*xorm.Session) error) error {
+	if useTx && m.transactionalDDL() {
+		return m.db.Transaction(ctx, f)
 	}
 
-	return nil
+	session := m.db.NewSession()
+	defer session.Close()
+	return f(session)
 }
 
 func (m *Migrate) runMigration(ctx context.Context, migration *Migration) error {
@@ -166,16 +269,31 @@ func (m *Migrate) runMigration(ctx context.Context, migration *Migration) error
 		return ErrMissingID
 	}
 
-	if !m.migrationDidRun(ctx, migration) {
-		if err := migration.Migrate(m.db); err != nil {
-			return err
-		}
+	if m.migrationDidRun(ctx, migration) {
+		return nil
+	}
 
-		if err := m.insertMigration(ctx, migration.ID); err != nil {
+	return m.withSession(ctx, !migration.NoTransaction, func(session *xorm.Session) error {
+		start := time.Now()
+		if err := migration.Migrate(ctx, session); err != nil {
 			return err
 		}
+		return m.insertMigration(ctx, session, migration, time.Since(start))
+	})
+}
+
+// migrationColumns returns the columns of the migration table besides the ID
+// column, they are added to the tables created by the former versions
+func (m *Migrate) migrationColumns() [][2]string {
+	dialect := m.db.Dialect()
+	sqlType := func(name string, length int) string {
+		return dialect.SqlType(&core.Column{SQLType: core.SQLType{Name: name}, Length: length})
+	}
+	return [][2]string{
+		{"applied_at", sqlType(core.DateTime, 0)},
+		{"checksum", sqlType(core.Varchar, 64)},
+		{"duration", sqlType(core.BigInt, 0)},
 	}
-	return nil
 }
 
 func (m *Migrate) createMigrationTableIfNotExists(ctx context.Context) error {
@@ -184,16 +302,40 @@ func (m *Migrate) createMigrationTableIfNotExists(ctx context.Context) error {
 		return err
 	}
 	if exists {
-		return nil
+		return m.upgradeMigrationTable(ctx)
 	}
 
-	sql := fmt.Sprintf("CREATE TABLE %s (%s VARCHAR(255) PRIMARY KEY)", m.options.TableName, m.options.IDColumnName)
+	var cols = []string{fmt.Sprintf("%s VARCHAR(255) PRIMARY KEY", m.options.IDColumnName)}
+	for _, col := range m.migrationColumns() {
+		cols = append(cols, col[0]+" "+col[1])
+	}
+	sql := fmt.Sprintf("CREATE TABLE %s (%s)", m.options.TableName, strings.Join(cols, ", "))
 	if _, err := m.db.Exec(ctx, sql); err != nil {
 		return err
 	}
 	return nil
 }
 
+// upgradeMigrationTable adds the missing columns to the migration table which
+// has the ID column only
+func (m *Migrate) upgradeMigrationTable(ctx context.Context) error {
+	for _, col := range m.migrationColumns() {
+		exists, err := m.db.Dialect().IsColumnExist(ctx, m.options.TableName, col[0])
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+
+		sql := fmt.Sprintf("ALTER TABLE %s ADD %s %s", m.options.TableName, col[0], col[1])
+		if _, err := m.db.Exec(ctx, sql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *Migrate) migrationDidRun(ctx context.Context, mig *Migration) bool {
 	row := m.db.DB().QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s = ?", m.options.TableName, m.options.IDColumnName), mig.ID)
 	var count int
@@ -208,8 +350,10 @@ func (m *Migrate) isFirstRun(ctx context.Context) bool {
 	return count == 0
 }
 
-func (m *Migrate) insertMigration(ctx context.Context, id string) error {
-	sql := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?)", m.options.TableName, m.options.IDColumnName)
-	_, err := m.db.Exec(ctx, sql, id)
+func (m *Migrate) insertMigration(ctx context.Context, session *xorm.Session, mig *Migration, duration time.Duration) error {
+	sql := fmt.Sprintf("INSERT INTO %s (%s, applied_at, checksum, duration) VALUES (?, ?, ?, ?)",
+		m.options.TableName, m.options.IDColumnName)
+	_, err := session.Exec(ctx, sql, mig.ID, time.Now().UTC().Format(appliedAtLayout),
+		mig.checksum(), int64(duration/time.Millisecond))
 	return err
 }
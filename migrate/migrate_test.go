@@ -2,10 +2,13 @@ package migrate
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/go-xorm/xorm"
 	_ "github.com/mattn/go-sqlite3"
@@ -31,20 +34,20 @@ var (
 	migrations = []*Migration{
 		{
 			ID: "201608301400",
-			Migrate: func(tx *xorm.Engine) error {
-				return tx.Sync2(context.Background(), &Person{})
+			Migrate: func(ctx context.Context, tx *xorm.Session) error {
+				return tx.Sync2(ctx, &Person{})
 			},
-			Rollback: func(tx *xorm.Engine) error {
-				return tx.DropTables(context.Background(), &Person{})
+			Rollback: func(ctx context.Context, tx *xorm.Session) error {
+				return tx.DropTable(ctx, &Person{})
 			},
 		},
 		{
 			ID: "201608301430",
-			Migrate: func(tx *xorm.Engine) error {
-				return tx.Sync2(context.Background(), &Pet{})
+			Migrate: func(ctx context.Context, tx *xorm.Session) error {
+				return tx.Sync2(ctx, &Pet{})
 			},
-			Rollback: func(tx *xorm.Engine) error {
-				return tx.DropTables(context.Background(), &Pet{})
+			Rollback: func(ctx context.Context, tx *xorm.Session) error {
+				return tx.DropTable(ctx, &Pet{})
 			},
 		},
 	}
@@ -103,11 +106,11 @@ func TestInitSchema(t *testing.T) {
 	}
 
 	m := New(db, DefaultOptions, migrations)
-	m.InitSchema(func(tx *xorm.Engine) error {
-		if err := tx.Sync2(context.Background(), &Person{}); err != nil {
+	m.InitSchema(func(ctx context.Context, tx *xorm.Session) error {
+		if err := tx.Sync2(ctx, &Person{}); err != nil {
 			return err
 		}
-		if err := tx.Sync2(context.Background(), &Pet{}); err != nil {
+		if err := tx.Sync2(ctx, &Pet{}); err != nil {
 			return err
 		}
 		return nil
@@ -134,7 +137,7 @@ func TestMissingID(t *testing.T) {
 
 	migrationsMissingID := []*Migration{
 		{
-			Migrate: func(tx *xorm.Engine) error {
+			Migrate: func(ctx context.Context, tx *xorm.Session) error {
 				return nil
 			},
 		},
@@ -144,6 +147,257 @@ func TestMissingID(t *testing.T) {
 	assert.Equal(t, ErrMissingID, m.Migrate(context.Background()))
 }
 
+func TestMigrationFailed(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	if db != nil {
+		defer db.Close()
+	}
+	assert.NoError(t, db.DB().PingContext(context.Background()))
+
+	errFailed := errors.New("failed")
+	m := New(db, DefaultOptions, []*Migration{
+		{
+			ID: "201608301400",
+			Migrate: func(ctx context.Context, tx *xorm.Session) error {
+				if err := tx.Sync2(ctx, &Person{}); err != nil {
+					return err
+				}
+				return errFailed
+			},
+		},
+	})
+
+	// the changes of the failed migration are rolled back
+	assert.Equal(t, errFailed, m.Migrate(context.Background()))
+	exists, _ := db.IsTableExist(context.Background(), &Person{})
+	assert.False(t, exists)
+	assert.Equal(t, 0, tableCount(db, "migrations"))
+}
+
+func TestMigrationLock(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	if db != nil {
+		defer db.Close()
+	}
+	assert.NoError(t, db.DB().PingContext(context.Background()))
+
+	options := &Options{
+		TableName:    "migrations",
+		IDColumnName: "id",
+		LockTimeout:  200 * time.Millisecond,
+	}
+	m := New(db, options, migrations)
+
+	unlock, err := m.lockRow(context.Background())
+	assert.NoError(t, err)
+
+	// another runner waits for the lock until the timeout
+	assert.Equal(t, ErrLockTimeout, New(db, options, migrations).Migrate(context.Background()))
+	assert.NoError(t, unlock())
+
+	assert.NoError(t, m.Migrate(context.Background()))
+	assert.Equal(t, 2, tableCount(db, "migrations"))
+	assert.Equal(t, 0, tableCount(db, "migrations_lock"))
+
+	// the lock row of a crashed runner is released by ForceUnlock
+	insertLock := "INSERT INTO migrations_lock (id, locked_at, locked_by) VALUES (?, ?, ?)"
+	_, err = db.Exec(context.Background(), insertLock, m.lockName(), time.Now().UTC().Format(lockedAtLayout), "crashed:1")
+	assert.NoError(t, err)
+	assert.Equal(t, ErrLockTimeout, m.Migrate(context.Background()))
+	assert.NoError(t, m.ForceUnlock(context.Background()))
+	assert.NoError(t, m.Migrate(context.Background()))
+	assert.Equal(t, 0, tableCount(db, "migrations_lock"))
+
+	// or as stale after the TTL
+	_, err = db.Exec(context.Background(), insertLock, m.lockName(), time.Now().Add(-time.Hour).UTC().Format(lockedAtLayout), "crashed:1")
+	assert.NoError(t, err)
+	options.LockTTL = time.Minute
+	assert.NoError(t, New(db, options, migrations).Migrate(context.Background()))
+	assert.Equal(t, 0, tableCount(db, "migrations_lock"))
+}
+
+func TestMigrationStatus(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	if db != nil {
+		defer db.Close()
+	}
+	assert.NoError(t, db.DB().PingContext(context.Background()))
+
+	m := New(db, DefaultOptions, migrations)
+
+	// the status is read without creating the tables
+	_, err = m.Status(context.Background())
+	assert.Equal(t, ErrNotInitialized, err)
+	pending, err := m.Pending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, len(migrations), len(pending))
+	exists, _ := db.IsTableExist(context.Background(), "migrations")
+	assert.False(t, exists)
+	exists, _ = db.IsTableExist(context.Background(), "migrations_lock")
+	assert.False(t, exists)
+
+	assert.Equal(t, ErrUnknownID, m.MigrateTo(context.Background(), "unknown"))
+	assert.NoError(t, m.MigrateTo(context.Background(), "201608301400"))
+
+	statuses, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(statuses)) {
+		assert.True(t, statuses[0].Applied)
+		assert.False(t, statuses[0].AppliedAt.IsZero())
+		assert.False(t, statuses[1].Applied)
+	}
+
+	pending, err = m.Pending(context.Background())
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(pending)) {
+		assert.Equal(t, "201608301430", pending[0].ID)
+	}
+
+	assert.NoError(t, m.Migrate(context.Background()))
+	assert.NoError(t, m.Redo(context.Background()))
+	exists, _ = db.IsTableExist(context.Background(), &Pet{})
+	assert.True(t, exists)
+	assert.Equal(t, 2, tableCount(db, "migrations"))
+
+	assert.NoError(t, m.RollbackTo(context.Background(), "201608301400"))
+	exists, _ = db.IsTableExist(context.Background(), &Pet{})
+	assert.False(t, exists)
+	exists, _ = db.IsTableExist(context.Background(), &Person{})
+	assert.True(t, exists)
+	assert.Equal(t, 1, tableCount(db, "migrations"))
+}
+
+func TestUpgradeMigrationTable(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	if db != nil {
+		defer db.Close()
+	}
+	assert.NoError(t, db.DB().PingContext(context.Background()))
+
+	// the migration table of the former versions has the ID column only
+	_, err = db.Exec(context.Background(), "CREATE TABLE migrations (id VARCHAR(255) PRIMARY KEY)")
+	assert.NoError(t, err)
+	_, err = db.Exec(context.Background(), "INSERT INTO migrations (id) VALUES (?)", "201608301400")
+	assert.NoError(t, err)
+
+	m := New(db, DefaultOptions, migrations)
+	statuses, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(statuses)) {
+		assert.True(t, statuses[0].Applied)
+		assert.True(t, statuses[0].AppliedAt.IsZero())
+		assert.False(t, statuses[1].Applied)
+	}
+
+	assert.NoError(t, m.Migrate(context.Background()))
+	assert.Equal(t, 2, tableCount(db, "migrations"))
+}
+
+func TestSQLFileMigration(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	if db != nil {
+		defer db.Close()
+	}
+	assert.NoError(t, db.DB().PingContext(context.Background()))
+
+	fsys := fstest.MapFS{
+		"sql/201608301500_create_toy.up.sql": &fstest.MapFile{Data: []byte(`
+-- the toys; of the pets
+CREATE TABLE toy (id INTEGER PRIMARY KEY, name VARCHAR(255));
+INSERT INTO toy (name) VALUES ('ball; red');
+`)},
+		"sql/201608301500_create_toy.down.sql": &fstest.MapFile{Data: []byte("DROP TABLE toy;")},
+		"sql/README.md":                        &fstest.MapFile{Data: []byte("not a migration")},
+	}
+	files, err := LoadFS(fsys, "sql")
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(files)) {
+		assert.Equal(t, "201608301500", files[0].ID)
+		assert.Equal(t, "create_toy", files[0].Name)
+		assert.NotNil(t, files[0].Rollback)
+	}
+
+	// the file migration runs after the Go migrations by the ID
+	m := New(db, DefaultOptions, append(files, migrations...))
+	assert.NoError(t, m.Migrate(context.Background()))
+	assert.Equal(t, 3, tableCount(db, "migrations"))
+	assert.Equal(t, 1, tableCount(db, "toy"))
+
+	pending, err := m.Pending(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(pending))
+
+	assert.NoError(t, m.RollbackLast(context.Background()))
+	exists, _ := db.IsTableExist(context.Background(), "toy")
+	assert.False(t, exists)
+	exists, _ = db.IsTableExist(context.Background(), &Pet{})
+	assert.True(t, exists)
+
+	_, err = LoadFS(fstest.MapFS{
+		"0001_a.down.sql": &fstest.MapFile{Data: []byte("SELECT 1")},
+	}, ".")
+	assert.Error(t, err)
+}
+
+func TestMigrationChecksum(t *testing.T) {
+	os.Remove(dbName)
+
+	db, err := xorm.NewEngine("sqlite3", dbName)
+	assert.NoError(t, err)
+	if db != nil {
+		defer db.Close()
+	}
+	assert.NoError(t, db.DB().PingContext(context.Background()))
+
+	load := func(query string) []*Migration {
+		files, err := LoadFS(fstest.MapFS{
+			"0001_create_toy.up.sql": &fstest.MapFile{Data: []byte(query)},
+		}, ".")
+		assert.NoError(t, err)
+		return files
+	}
+
+	files := load("CREATE TABLE toy (id INTEGER PRIMARY KEY);")
+	assert.NoError(t, New(db, DefaultOptions, files).Migrate(context.Background()))
+
+	// the applied migration is changed
+	m := New(db, DefaultOptions, load("CREATE TABLE toy (id INTEGER PRIMARY KEY, name TEXT);"))
+	assert.True(t, errors.Is(m.Migrate(context.Background()), ErrChecksumMismatch))
+
+	statuses, err := m.Status(context.Background())
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(statuses)) {
+		assert.True(t, statuses[0].Changed)
+	}
+
+	options := *DefaultOptions
+	options.WarnChecksumMismatch = true
+	assert.NoError(t, New(db, &options, m.migrations).Migrate(context.Background()))
+
+	assert.NoError(t, m.Repair(context.Background()))
+	assert.NoError(t, m.Migrate(context.Background()))
+	statuses, err = m.Status(context.Background())
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(statuses)) {
+		assert.False(t, statuses[0].Changed)
+	}
+}
+
 func tableCount(db *xorm.Engine, tableName string) (count int) {
 	row := db.DB().QueryRow(context.Background(), fmt.Sprintf("SELECT COUNT(*) FROM %s", tableName))
 	row.Scan(&count)
@@ -0,0 +1,113 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-xorm/xorm"
+)
+
+// sqlFilePattern matches the names of the SQL files, e.g. 0001_create_user.up.sql
+var sqlFilePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// NoTransactionDirective is the comment in an up file which makes the
+// migration run without a transaction as Migration.NoTransaction.
+const NoTransactionDirective = "-- xorm:no-transaction"
+
+// LoadDir loads the migrations of the SQL files in the directory, see LoadFS.
+func LoadDir(dir string) ([]*Migration, error) {
+	return LoadFS(os.DirFS(dir), ".")
+}
+
+// LoadFS loads the migrations of the SQL files in the directory of fsys. The
+// files are named NNNN_name.up.sql and NNNN_name.down.sql, where NNNN is the
+// ID of the migration. The up file is required and the down file is the
+// rollback of the migration. The statements of the files are split by
+// xorm.SplitSQL and executed in order.
+func LoadFS(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var migrations []*Migration
+	var byID = make(map[string]*Migration)
+	for _, entry := range entries {
+		match := sqlFilePattern.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		id, name, direction := match[1], match[2], match[3]
+		mig, ok := byID[id]
+		if !ok {
+			mig = &Migration{ID: id, Name: name}
+			byID[id] = mig
+			migrations = append(migrations, mig)
+		} else if mig.Name != name {
+			return nil, fmt.Errorf("migration %s has files of different names %s and %s", id, mig.Name, name)
+		}
+
+		query := string(data)
+		if direction == "up" {
+			mig.upSQL = query
+			mig.Migrate = sqlMigrateFunc(query)
+			mig.NoTransaction = strings.Contains(query, NoTransactionDirective)
+		} else {
+			mig.Rollback = RollbackFunc(sqlMigrateFunc(query))
+		}
+	}
+
+	for _, mig := range migrations {
+		if mig.Migrate == nil {
+			return nil, fmt.Errorf("migration %s_%s has no up file", mig.ID, mig.Name)
+		}
+	}
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return lessID(migrations[i].ID, migrations[j].ID)
+	})
+	return migrations, nil
+}
+
+func sqlMigrateFunc(query string) MigrateFunc {
+	return func(ctx context.Context, session *xorm.Session) error {
+		for _, stmt := range xorm.SplitSQL(session.Dialect().DBType(), query) {
+			if _, err := session.Exec(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// lessID compares the IDs of the migrations, the numeric IDs are compared by
+// their values so that 0002 and 10 are in order
+func lessID(a, b string) bool {
+	if isDigits(a) && isDigits(b) {
+		a, b = strings.TrimLeft(a, "0"), strings.TrimLeft(b, "0")
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+	}
+	return a < b
+}
@@ -0,0 +1,249 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// appliedAtLayout is the layout of the applied time written to the migration
+// table, which is accepted by the datetime types of all the databases
+const appliedAtLayout = "2006-01-02 15:04:05"
+
+// MigrationStatus is the status of a migration in the database.
+type MigrationStatus struct {
+	ID      string
+	Applied bool
+	// AppliedAt is zero if the migration is pending or it was applied before
+	// the migration table had the column.
+	AppliedAt time.Time
+	Duration  time.Duration
+	Checksum  string
+	// Changed is true if the applied migration has been changed since it
+	// was recorded.
+	Changed bool
+}
+
+type migrationRecord struct {
+	appliedAt time.Time
+	checksum  string
+	duration  time.Duration
+}
+
+// changed returns true if the checksum of the migration is different from the
+// recorded one, the migrations recorded without checksums are not checked
+func (record *migrationRecord) changed(mig *Migration) bool {
+	return record.checksum != "" && record.checksum != mig.checksum()
+}
+
+func parseAppliedAt(s string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, appliedAtLayout} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// appliedMigrations returns the records of the migration table by the IDs, the
+// columns missing from the tables of the former versions are read as empty
+func (m *Migrate) appliedMigrations(ctx context.Context) (map[string]*migrationRecord, error) {
+	sql := fmt.Sprintf("SELECT * FROM %s", m.options.TableName)
+	rows, err := m.db.QueryString(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+
+	var records = make(map[string]*migrationRecord, len(rows))
+	for _, row := range rows {
+		record := &migrationRecord{
+			appliedAt: parseAppliedAt(row["applied_at"]),
+			checksum:  row["checksum"],
+		}
+		if ms, err := strconv.ParseInt(row["duration"], 10, 64); err == nil {
+			record.duration = time.Duration(ms) * time.Millisecond
+		}
+		records[row[m.options.IDColumnName]] = record
+	}
+	return records, nil
+}
+
+func (m *Migrate) migrationIndex(id string) int {
+	for i, migration := range m.migrations {
+		if migration.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// Status returns the status of the migrations in their order. It only reads
+// the migration table without the migration lock, and returns
+// ErrNotInitialized if the table doesn't exist.
+func (m *Migrate) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	exists, err := m.db.IsTableExist(ctx, m.options.TableName)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotInitialized
+	}
+
+	records, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses = make([]*MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := &MigrationStatus{ID: migration.ID}
+		if record, ok := records[migration.ID]; ok {
+			status.Applied = true
+			status.AppliedAt = record.appliedAt
+			status.Duration = record.duration
+			status.Checksum = record.checksum
+			status.Changed = record.changed(migration)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Pending returns the migrations which did not run yet, they are all the
+// migrations if the migration table is not initialized.
+func (m *Migrate) Pending(ctx context.Context) ([]*Migration, error) {
+	statuses, err := m.Status(ctx)
+	if err == ErrNotInitialized {
+		return append([]*Migration(nil), m.migrations...), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []*Migration
+	for i, status := range statuses {
+		if !status.Applied {
+			pending = append(pending, m.migrations[i])
+		}
+	}
+	return pending, nil
+}
+
+// MigrateTo executes the migrations which did not run yet up to and including
+// the migration of the id.
+func (m *Migrate) MigrateTo(ctx context.Context, id string) error {
+	index := m.migrationIndex(id)
+	if index < 0 {
+		return ErrUnknownID
+	}
+
+	return m.withLock(ctx, func() error {
+		if err := m.createMigrationTableIfNotExists(ctx); err != nil {
+			return err
+		}
+		if err := m.verifyChecksums(ctx); err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations[:index+1] {
+			if err := m.runMigration(ctx, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RollbackTo undo the migrations after the migration of the id in the reverse
+// order, the migration of the id is kept.
+func (m *Migrate) RollbackTo(ctx context.Context, id string) error {
+	index := m.migrationIndex(id)
+	if index < 0 {
+		return ErrUnknownID
+	}
+
+	return m.withLock(ctx, func() error {
+		for i := len(m.migrations) - 1; i > index; i-- {
+			migration := m.migrations[i]
+			if !m.migrationDidRun(ctx, migration) {
+				continue
+			}
+			if err := m.RollbackMigration(ctx, migration); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Redo undo the last migration and executes it again.
+func (m *Migrate) Redo(ctx context.Context) error {
+	if len(m.migrations) == 0 {
+		return ErrNoMigrationDefined
+	}
+
+	return m.withLock(ctx, func() error {
+		lastRunnedMigration, err := m.getLastRunnedMigration(ctx)
+		if err != nil {
+			return err
+		}
+
+		if err := m.RollbackMigration(ctx, lastRunnedMigration); err != nil {
+			return err
+		}
+		return m.runMigration(ctx, lastRunnedMigration)
+	})
+}
+
+// verifyChecksums returns ErrChecksumMismatch if any applied migration has
+// been changed, or logs them if Options.WarnChecksumMismatch is true
+func (m *Migrate) verifyChecksums(ctx context.Context) error {
+	records, err := m.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	for _, migration := range m.migrations {
+		if record, ok := records[migration.ID]; ok && record.changed(migration) {
+			changed = append(changed, migration.ID)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	if m.options.WarnChecksumMismatch {
+		m.db.Logger()(ctx).Warnf("applied migrations have been changed: %s", strings.Join(changed, ", "))
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(changed, ", "))
+}
+
+// Repair records the checksums of the applied migrations as they are now,
+// it's used after the changes of the applied migrations are confirmed.
+func (m *Migrate) Repair(ctx context.Context) error {
+	return m.withLock(ctx, func() error {
+		if err := m.createMigrationTableIfNotExists(ctx); err != nil {
+			return err
+		}
+		records, err := m.appliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		sql := fmt.Sprintf("UPDATE %s SET checksum = ? WHERE %s = ?", m.options.TableName, m.options.IDColumnName)
+		for _, migration := range m.migrations {
+			record, ok := records[migration.ID]
+			if !ok || record.checksum == migration.checksum() {
+				continue
+			}
+			if _, err := m.db.Exec(ctx, sql, migration.checksum(), migration.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"hash/crc32"
+	"io"
 	"reflect"
 	"strings"
 	"time"
@@ -24,6 +25,9 @@ type Session struct {
 	db                     *core.DB
 	engine                 *Engine
 	tx                     *core.Tx
+	txCtx                  context.Context
+	resetTxSQL             string
+	savepoints             []string
 	statement              Statement
 	isAutoCommit           bool
 	isCommitedOrRollbacked bool
@@ -44,6 +48,15 @@ type Session struct {
 
 	afterProcessors []executedProcessor
 
+	// collects the cascade fields of the beans being found
+	cascadeBatch *cascadeBatch
+
+	// the statements are written to it instead of being executed
+	dryRun io.Writer
+	// Sync2 drops the columns which are not declared by the structs
+	dropUnused bool
+	forceDrop  bool
+
 	prepareStmt bool
 	stmtCache   map[uint32]*core.Stmt //key: hash.Hash32 of (queryStr, len(queryStr))
 
@@ -67,6 +80,9 @@ func (session *Session) Init() {
 	session.statement.Engine = session.engine
 	session.isAutoCommit = true
 	session.isCommitedOrRollbacked = false
+	session.txCtx = nil
+	session.resetTxSQL = ""
+	session.savepoints = nil
 	session.isAutoClose = false
 	session.autoResetStatement = true
 	session.prepareStmt = false
@@ -95,9 +111,12 @@ func (session *Session) Close() {
 		// When Close be called, if session is a transaction and do not call
 		// Commit or Rollback, then call Rollback.
 		if session.tx != nil && !session.isCommitedOrRollbacked {
+			session.savepoints = nil
 			session.Rollback()
 		}
 		session.tx = nil
+		session.txCtx = nil
+		session.savepoints = nil
 		session.stmtCache = nil
 		session.db = nil
 	}
@@ -148,6 +167,46 @@ func (session *Session) Alias(alias string) *Session {
 	return session
 }
 
+// With adds a common table expression to the query, the query could be a
+// *Session, a *builder.Builder or a string with args. The expression is used
+// as a table by Table(name), so that the results are mapped into the beans.
+func (session *Session) With(name string, query interface{}, args ...interface{}) *Session {
+	session.statement.With(name, query, args...)
+	return session
+}
+
+// WithRecursive adds a recursive common table expression to the query, the
+// query usually unions the initial rows and the rows joining the name itself.
+func (session *Session) WithRecursive(name string, query interface{}, args ...interface{}) *Session {
+	session.statement.WithRecursive(name, query, args...)
+	return session
+}
+
+// Union combines the results of the other session and removes the duplicated
+// rows, the ORDER BY and LIMIT of the session are applied to the combined rows
+func (session *Session) Union(other *Session) *Session {
+	session.statement.combine("UNION", other)
+	return session
+}
+
+// UnionAll combines the results of the other session
+func (session *Session) UnionAll(other *Session) *Session {
+	session.statement.combine("UNION ALL", other)
+	return session
+}
+
+// Intersect keeps the results which are also the results of the other session
+func (session *Session) Intersect(other *Session) *Session {
+	session.statement.combine("INTERSECT", other)
+	return session
+}
+
+// Except removes the results which are the results of the other session
+func (session *Session) Except(other *Session) *Session {
+	session.statement.combine("EXCEPT", other)
+	return session
+}
+
 // NoCascade indicate that no cascade load child object
 func (session *Session) NoCascade() *Session {
 	session.statement.UseCascade = false
@@ -245,6 +304,11 @@ func (session *Session) DB() *core.DB {
 	return session.db
 }
 
+// Dialect returns the dialect of the engine of the session
+func (session *Session) Dialect() core.Dialect {
+	return session.engine.dialect
+}
+
 func cleanupProcessorsClosures(slices *[]func(interface{})) {
 	if len(*slices) > 0 {
 		*slices = make([]func(interface{}), 0)
@@ -659,8 +723,10 @@ func (session *Session) slice2Bean(ctx context.Context, scanResults []interface{
 					}
 
 					hasAssigned = true
-					if len(table.PrimaryKeys) != 1 {
-						return nil, errors.New("unsupported non or composited primary key cascade")
+					if len(table.PrimaryKeys) == 0 {
+						return nil, errors.New("unsupported non primary key cascade")
+					} else if len(table.PrimaryKeys) > 1 {
+						return nil, ErrCompositeCascade
 					}
 					var pk = make(core.PK, len(table.PrimaryKeys))
 					pk[0], err = asKind(vv, rawValueType)
@@ -668,7 +734,9 @@ func (session *Session) slice2Bean(ctx context.Context, scanResults []interface{
 						return nil, err
 					}
 
-					if !isPKZero(pk) {
+					if !isPKZero(pk) && session.cascadeBatch != nil {
+						session.cascadeBatch.add(table, *fieldValue, pk[0])
+					} else if !isPKZero(pk) {
 						// !nashtsai! TODO for hasOne relationship, it's preferred to use join query for eager fetch
 						// however, also need to consider adding a 'lazy' attribute to xorm tag which allow hasOne
 						// property to be fetched lazily
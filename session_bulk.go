@@ -0,0 +1,505 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lingochamp/core"
+)
+
+// BulkIterFunc returns the beans to be inserted by BulkInsert one by one, it
+// returns a nil bean when there are no more beans.
+type BulkIterFunc func() (interface{}, error)
+
+// bulkInsertChunkSize is the max count of the beans of a multi-row insert
+// when BulkInsert falls back to them
+const bulkInsertChunkSize = 1000
+
+// BulkInsert inserts a large number of records, beans is a slice of structs
+// or a BulkIterFunc. The records are streamed through COPY FROM STDIN on
+// Postgres with lib/pq, and LOAD DATA LOCAL INFILE on MySQL with
+// go-sql-driver/mysql once RegisterMySQLReaderHandler is called. The other
+// drivers and dialects fall back to multi-row inserts in chunks.
+//
+// COPY and LOAD DATA don't call the insert processors, and won't be used when
+// OnConflict or Returning is set. LOAD DATA needs local infile to be enabled
+// on the MySQL server, and it fails if the server reports any warning, e.g. a
+// duplicate key, instead of skipping the rows.
+func (session *Session) BulkInsert(ctx context.Context, beans interface{}) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	next, err := bulkIterator(beans)
+	if err != nil {
+		return 0, err
+	}
+
+	first, err := next()
+	if err != nil || first == nil {
+		return 0, err
+	}
+
+	if err := session.statement.setRefValue(rValue(first)); err != nil {
+		return 0, err
+	}
+	if len(session.statement.TableName()) <= 0 {
+		return 0, ErrTableNotFound
+	}
+
+	if session.statement.conflict == nil && len(session.statement.returning) == 0 {
+		switch session.engine.dialect.DBType() {
+		case core.POSTGRES:
+			if session.engine.isDriver("github.com/lib/pq") {
+				return session.bulkCopyIn(ctx, first, next)
+			}
+		case core.MYSQL:
+			if session.engine.isDriver("github.com/go-sql-driver/mysql") && mysqlReaderRegistered() {
+				return session.bulkLoadData(ctx, first, next)
+			}
+		}
+	}
+	return session.bulkInsertChunks(ctx, first, next)
+}
+
+// isDriver reports whether the driver of the engine is of the package, which
+// may be vendored. The drivers aren't imported so that they're registered by
+// the application only.
+func (engine *Engine) isDriver(pkgPath string) bool {
+	t := reflect.TypeOf(engine.DB().Driver())
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.PkgPath() == pkgPath || strings.HasSuffix(t.PkgPath(), "/vendor/"+pkgPath)
+}
+
+func bulkIterator(beans interface{}) (BulkIterFunc, error) {
+	switch f := beans.(type) {
+	case BulkIterFunc:
+		return f, nil
+	case func() (interface{}, error):
+		return f, nil
+	}
+
+	sliceValue := reflect.Indirect(reflect.ValueOf(beans))
+	if sliceValue.Kind() != reflect.Slice {
+		return nil, ErrParamsType
+	}
+
+	var idx int
+	return func() (interface{}, error) {
+		if idx >= sliceValue.Len() {
+			return nil, nil
+		}
+		elem := sliceValue.Index(idx)
+		idx++
+		if elem.Kind() == reflect.Struct {
+			return elem.Addr().Interface(), nil
+		}
+		return elem.Interface(), nil
+	}, nil
+}
+
+// bulkInsertChunks inserts the beans by multi-row inserts of at most
+// bulkInsertChunkSize beans, which are split again by the parameter limit.
+// The chunks are inserted in a transaction, so that all the beans or none of
+// them are inserted.
+func (session *Session) bulkInsertChunks(ctx context.Context, first interface{}, next BulkIterFunc) (affected int64, err error) {
+	// the statement is reset after every insert, keep it for all the chunks
+	var statement = session.statement
+	defer session.resetStatement()
+
+	endTx, err := session.beginImplicitTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer endTx(&err)
+
+	var chunkSize = 1
+	if session.engine.SupportInsertMany() {
+		chunkSize = bulkInsertChunkSize
+	}
+
+	var sliceType = reflect.SliceOf(reflect.TypeOf(first))
+	var chunk = reflect.MakeSlice(sliceType, 0, chunkSize)
+	var flush = func() error {
+		if chunk.Len() == 0 {
+			return nil
+		}
+
+		session.statement = statement
+		var cnt int64
+		var err error
+		if chunk.Len() > 1 {
+			cnt, err = session.innerInsertMulti(ctx, chunk.Interface())
+		} else {
+			cnt, err = session.innerInsert(ctx, chunk.Index(0).Interface())
+		}
+		affected += cnt
+		chunk = reflect.MakeSlice(sliceType, 0, chunkSize)
+		return err
+	}
+
+	for bean := first; bean != nil; {
+		chunk = reflect.Append(chunk, reflect.ValueOf(bean))
+		if chunk.Len() >= chunkSize {
+			if err = flush(); err != nil {
+				return 0, err
+			}
+		}
+
+		if bean, err = next(); err != nil {
+			return 0, err
+		}
+	}
+	if err = flush(); err != nil {
+		return 0, err
+	}
+	return affected, nil
+}
+
+// bulkColumns returns the columns to be inserted, the autoincrement column is
+// only inserted when it's not zero in the first bean
+func (session *Session) bulkColumns(first interface{}) ([]*core.Column, error) {
+	var table = session.statement.RefTable
+	var cols = make([]*core.Column, 0, len(table.ColumnsSeq()))
+	for _, col := range table.Columns() {
+		if col.IsAutoIncrement {
+			fieldValue, err := col.ValueOf(first)
+			if err != nil {
+				return nil, err
+			}
+			if isZero(fieldValue.Interface()) {
+				continue
+			}
+		}
+		if col.MapType == core.ONLYFROMDB || col.IsDeleted {
+			continue
+		}
+		if session.statement.ColumnStr != "" {
+			if _, ok := getFlagForColumn(session.statement.columnMap, col); !ok {
+				continue
+			}
+		}
+		if session.statement.OmitStr != "" {
+			if _, ok := getFlagForColumn(session.statement.columnMap, col); ok {
+				continue
+			}
+		}
+		cols = append(cols, col)
+	}
+	if len(cols) == 0 {
+		return nil, errors.New("No content found to be inserted")
+	}
+	return cols, nil
+}
+
+// bulkValues returns the values of the columns of the bean, the created,
+// updated and version columns of the bean are set as the insert does
+func (session *Session) bulkValues(cols []*core.Column, bean interface{}, useAutoTime, checkVersion bool) ([]interface{}, error) {
+	var args = make([]interface{}, 0, len(cols))
+	for _, col := range cols {
+		if (col.IsCreated || col.IsUpdated) && useAutoTime {
+			val, t := session.engine.nowTime(col)
+			setColumnTime(bean, col, t)
+			args = append(args, val)
+		} else if col.IsVersion && checkVersion {
+			setColumnInt(bean, col, 1)
+			args = append(args, 1)
+		} else {
+			fieldValue, err := col.ValueOf(bean)
+			if err != nil {
+				return nil, err
+			}
+			arg, err := session.value2Interface(col, *fieldValue)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+	}
+	return args, nil
+}
+
+// bulkCopyIn inserts the beans by COPY FROM STDIN of lib/pq, it's only
+// allowed in a transaction, so a transaction is started if there isn't one.
+func (session *Session) bulkCopyIn(ctx context.Context, first interface{}, next BulkIterFunc) (affected int64, err error) {
+	cols, err := session.bulkColumns(first)
+	if err != nil {
+		return 0, err
+	}
+
+	var quotedCols = make([]string, len(cols))
+	for i, col := range cols {
+		quotedCols[i] = session.engine.Quote(col.Name)
+	}
+
+	var tableName = session.statement.TableName()
+	var quotedTable = session.engine.Quote(tableName)
+	if idx := strings.Index(tableName, "."); idx > -1 {
+		quotedTable = session.engine.Quote(tableName[:idx]) + "." + session.engine.Quote(tableName[idx+1:])
+	}
+	// lib/pq streams the rows of the prepared COPY statement
+	sqlStr := fmt.Sprintf("COPY %s (%s) FROM STDIN", quotedTable, strings.Join(quotedCols, ", "))
+
+	var useAutoTime = session.statement.UseAutoTime
+	var checkVersion = session.statement.checkVersion
+	var table = session.statement.RefTable
+	defer session.resetStatement()
+
+	endTx, err := session.beginImplicitTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer endTx(&err)
+	ctx = session.txContext(ctx)
+
+	if session.engine.showSQL {
+		session.engine.logger(ctx).Infof("[SQL] %v", sqlStr)
+	}
+	session.saveLastSQL(sqlStr)
+
+	stmt, err := session.tx.PrepareContext(ctx, sqlStr)
+	if err != nil {
+		return 0, session.engine.classifyError(err)
+	}
+	defer stmt.Close()
+
+	for bean := first; bean != nil; {
+		args, err := session.bulkValues(cols, bean, useAutoTime, checkVersion)
+		if err != nil {
+			return 0, err
+		}
+		if _, err = stmt.ExecContext(ctx, args...); err != nil {
+			return 0, session.engine.classifyError(err)
+		}
+		affected++
+
+		if bean, err = next(); err != nil {
+			return 0, err
+		}
+	}
+
+	// flush the buffered rows
+	if _, err = stmt.ExecContext(ctx); err != nil {
+		return 0, session.engine.classifyError(err)
+	}
+
+	if cacher := session.engine.getCacher2(table); cacher != nil {
+		session.cacheInsert(table, tableName)
+	}
+	return affected, nil
+}
+
+var (
+	bulkReaderMutex      sync.RWMutex
+	registerBulkReader   func(name string, handler func() io.Reader)
+	deregisterBulkReader func(name string)
+
+	// bulkReaderSeq numbers the reader handlers of the loads
+	bulkReaderSeq uint64
+)
+
+// RegisterMySQLReaderHandler enables LOAD DATA LOCAL INFILE of BulkInsert on
+// go-sql-driver/mysql. register and deregister should be the
+// RegisterReaderHandler and DeregisterReaderHandler of the driver linked by
+// the application, every load registers its reader by a unique name and
+// deregisters it after loading. It's called once before using the engines,
+// e.g.
+//
+//	xorm.RegisterMySQLReaderHandler(mysql.RegisterReaderHandler, mysql.DeregisterReaderHandler)
+func RegisterMySQLReaderHandler(register func(name string, handler func() io.Reader), deregister func(name string)) {
+	bulkReaderMutex.Lock()
+	registerBulkReader, deregisterBulkReader = register, deregister
+	bulkReaderMutex.Unlock()
+}
+
+func mysqlReaderRegistered() bool {
+	bulkReaderMutex.RLock()
+	defer bulkReaderMutex.RUnlock()
+	return registerBulkReader != nil
+}
+
+// registerLoadReader registers the reader of a load by a unique name, and
+// returns the name and the func to deregister it
+func registerLoadReader(r io.Reader) (string, func()) {
+	bulkReaderMutex.RLock()
+	register, deregister := registerBulkReader, deregisterBulkReader
+	bulkReaderMutex.RUnlock()
+
+	name := fmt.Sprintf("xorm_bulk_%d", atomic.AddUint64(&bulkReaderSeq, 1))
+	register(name, func() io.Reader {
+		return r
+	})
+	return name, func() {
+		if deregister != nil {
+			deregister(name)
+		}
+	}
+}
+
+// loadDataWarning is a warning of LOAD DATA, which is classified as the
+// error of the same number
+type loadDataWarning struct {
+	level   string
+	code    uint16
+	message string
+}
+
+func (w *loadDataWarning) Number() uint16 {
+	return w.code
+}
+
+func (w *loadDataWarning) Error() string {
+	return fmt.Sprintf("LOAD DATA %s %d: %s", w.level, w.code, w.message)
+}
+
+var loadDataEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	"\t", `\t`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\x00", `\0`,
+)
+
+// writeLoadDataValue writes the value in the default format of LOAD DATA
+func writeLoadDataValue(w *bufio.Writer, v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		w.WriteString(`\N`)
+	case string:
+		loadDataEscaper.WriteString(w, x)
+	case []byte:
+		if x == nil {
+			w.WriteString(`\N`)
+		} else {
+			loadDataEscaper.WriteString(w, string(x))
+		}
+	case bool:
+		if x {
+			w.WriteByte('1')
+		} else {
+			w.WriteByte('0')
+		}
+	case time.Time:
+		w.WriteString(x.Format("2006-01-02 15:04:05.999999"))
+	case int64:
+		w.WriteString(strconv.FormatInt(x, 10))
+	default:
+		loadDataEscaper.WriteString(w, fmt.Sprint(x))
+	}
+}
+
+// bulkLoadData inserts the beans by LOAD DATA LOCAL INFILE of MySQL, the
+// rows are streamed to the driver through the registered reader. LOAD DATA
+// LOCAL skips the rows of duplicate keys or invalid values with warnings, so
+// it's executed in a transaction which is rolled back if there are warnings.
+func (session *Session) bulkLoadData(ctx context.Context, first interface{}, next BulkIterFunc) (affected int64, err error) {
+	cols, err := session.bulkColumns(first)
+	if err != nil {
+		return 0, err
+	}
+
+	var quotedCols = make([]string, len(cols))
+	for i, col := range cols {
+		quotedCols[i] = session.engine.Quote(col.Name)
+	}
+
+	var useAutoTime = session.statement.UseAutoTime
+	var checkVersion = session.statement.checkVersion
+	var table = session.statement.RefTable
+	var tableName = session.statement.TableName()
+
+	endTx, err := session.beginImplicitTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer endTx(&err)
+
+	pr, pw := io.Pipe()
+	readerName, deregister := registerLoadReader(pr)
+	defer deregister()
+
+	var writeErr = make(chan error, 1)
+	go func() {
+		var err error
+		defer func() {
+			pw.CloseWithError(err)
+			writeErr <- err
+		}()
+
+		w := bufio.NewWriter(pw)
+		for bean := first; bean != nil; {
+			var args []interface{}
+			if args, err = session.bulkValues(cols, bean, useAutoTime, checkVersion); err != nil {
+				return
+			}
+			for i, arg := range args {
+				if i > 0 {
+					w.WriteByte('\t')
+				}
+				writeLoadDataValue(w, arg)
+			}
+			w.WriteByte('\n')
+
+			if bean, err = next(); err != nil {
+				return
+			}
+		}
+		err = w.Flush()
+	}()
+
+	sqlStr := fmt.Sprintf("LOAD DATA LOCAL INFILE 'Reader::%s' INTO TABLE %s "+
+		"FIELDS TERMINATED BY '\\t' ESCAPED BY '\\\\' LINES TERMINATED BY '\\n' (%s)",
+		readerName, session.engine.Quote(tableName), strings.Join(quotedCols, ", "))
+	res, err := session.exec(ctx, sqlStr)
+
+	// unblock the writer if the driver didn't read all the rows
+	pr.Close()
+	if wErr := <-writeErr; wErr != nil && wErr != io.ErrClosedPipe {
+		return 0, wErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err = session.loadDataWarning(ctx); err != nil {
+		return 0, err
+	}
+
+	if cacher := session.engine.getCacher2(table); cacher != nil {
+		session.cacheInsert(table, tableName)
+	}
+	return res.RowsAffected()
+}
+
+// loadDataWarning returns the first warning of LOAD DATA as an error, it's
+// read in the transaction so that it's on the connection of the load
+func (session *Session) loadDataWarning(ctx context.Context) error {
+	rows, err := session.queryRows(ctx, "SHOW WARNINGS")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return rows.Err()
+	}
+	var warning loadDataWarning
+	if err := rows.Scan(&warning.level, &warning.code, &warning.message); err != nil {
+		return err
+	}
+	return session.engine.classifyError(&warning)
+}
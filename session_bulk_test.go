@@ -0,0 +1,105 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	// the vendored driver has no DeregisterReaderHandler
+	RegisterMySQLReaderHandler(mysqldriver.RegisterReaderHandler, func(name string) {
+		mysqldriver.RegisterReaderHandler(name, nil)
+	})
+}
+
+func TestBulkInsert(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type BulkInsertStruct struct {
+		Id      int64
+		Name    string
+		Created time.Time `xorm:"created"`
+	}
+
+	assertSync(t, new(BulkInsertStruct))
+
+	ctx := context.Background()
+	var beans = make([]BulkInsertStruct, 1200)
+	for i := range beans {
+		beans[i].Name = fmt.Sprintf("bulk_%d", i)
+	}
+
+	cnt, err := testEngine.BulkInsert(ctx, beans)
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(beans), cnt)
+	assert.False(t, beans[len(beans)-1].Created.IsZero())
+
+	var idx int
+	cnt, err = testEngine.BulkInsert(ctx, BulkIterFunc(func() (interface{}, error) {
+		if idx >= 10 {
+			return nil, nil
+		}
+		idx++
+		return &BulkInsertStruct{Name: fmt.Sprintf("iter_%d", idx)}, nil
+	}))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 10, cnt)
+
+	total, err := testEngine.Count(ctx, new(BulkInsertStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(beans)+10, total)
+
+	cnt, err = testEngine.BulkInsert(ctx, []BulkInsertStruct{})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	_, err = testEngine.BulkInsert(ctx, BulkInsertStruct{})
+	assert.Equal(t, ErrParamsType, err)
+
+	// the implicit transaction should not be left on the session
+	session := testEngine.NewSession()
+	defer session.Close()
+	cnt, err = session.BulkInsert(ctx, []BulkInsertStruct{{Name: "session_1"}, {Name: "session_2"}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	total, err = session.Count(ctx, new(BulkInsertStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(beans)+12, total)
+
+	// duplicate keys fail the whole insert instead of being skipped, the
+	// chunks inserted before are rolled back
+	var dups = make([]BulkInsertStruct, bulkInsertChunkSize+1)
+	for i := range dups {
+		dups[i] = BulkInsertStruct{Id: int64(len(beans) + 100 + i), Name: fmt.Sprintf("dup_%d", i)}
+	}
+	dups[len(dups)-1].Id = 1
+	_, err = testEngine.BulkInsert(ctx, dups)
+	assert.True(t, errors.Is(err, ErrUniqueViolation))
+	total, err = testEngine.Count(ctx, new(BulkInsertStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(beans)+12, total)
+}
+
+func TestWriteLoadDataValue(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	for _, v := range []interface{}{nil, "a\tb\nc\\d", []byte("e\x00"), true, int64(-1),
+		time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC), 1.5} {
+		writeLoadDataValue(w, v)
+		w.WriteByte('|')
+	}
+	assert.NoError(t, w.Flush())
+	assert.EqualValues(t, `\N|a\tb\nc\\d|e\0|1|-1|2017-01-02 03:04:05|1.5|`, buf.String())
+}
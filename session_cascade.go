@@ -0,0 +1,126 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"reflect"
+
+	"github.com/lingochamp/core"
+)
+
+// cascadeBatch collects the belongs-to struct fields of the beans found by
+// Find, so that the related beans of every table are loaded by one query
+// instead of one query per field per bean.
+type cascadeBatch struct {
+	types  []reflect.Type
+	tables map[reflect.Type]*cascadeTable
+}
+
+type cascadeTable struct {
+	table  *core.Table
+	keys   []interface{}
+	fields map[string][]reflect.Value
+}
+
+func newCascadeBatch() *cascadeBatch {
+	return &cascadeBatch{
+		tables: make(map[reflect.Type]*cascadeTable),
+	}
+}
+
+// add records the field to be set with the related bean whose primary key is
+// the pk
+func (batch *cascadeBatch) add(table *core.Table, fieldValue reflect.Value, pk interface{}) {
+	t := fieldValue.Type()
+	ct, ok := batch.tables[t]
+	if !ok {
+		ct = &cascadeTable{
+			table:  table,
+			fields: make(map[string][]reflect.Value),
+		}
+		batch.tables[t] = ct
+		batch.types = append(batch.types, t)
+	}
+
+	key := relationKey(pk)
+	if _, ok := ct.fields[key]; !ok {
+		ct.keys = append(ct.keys, pk)
+	}
+	ct.fields[key] = append(ct.fields[key], fieldValue)
+}
+
+// loadCascades loads the related beans of the batch and sets them to the
+// fields, the cached beans are used if the related table has a cacher
+func (session *Session) loadCascades(ctx context.Context, batch *cascadeBatch) error {
+	// the processors of the found beans should run after their fields are set
+	var processors = session.afterProcessors
+	session.afterProcessors = make([]executedProcessor, 0)
+	defer func() {
+		session.afterProcessors = append(processors, session.afterProcessors...)
+	}()
+
+	for _, t := range batch.types {
+		ct := batch.tables[t]
+		pkCol := ct.table.PKColumns()[0]
+		tableName := session.engine.tbName(reflect.New(t).Elem())
+		cacher := session.engine.getCacher2(ct.table)
+
+		var loaded = make(map[string]reflect.Value, len(ct.keys))
+		var missed = ct.keys
+		if cacher != nil {
+			missed = make([]interface{}, 0, len(ct.keys))
+			for _, key := range ct.keys {
+				sid, err := core.PK{key}.ToString()
+				if err != nil {
+					return err
+				}
+				if bean := cacher.GetBean(tableName, sid); bean != nil {
+					loaded[relationKey(key)] = reflect.Indirect(reflect.ValueOf(bean))
+				} else {
+					missed = append(missed, key)
+				}
+			}
+		}
+
+		if len(missed) > 0 {
+			slicePtr := reflect.New(reflect.SliceOf(reflect.PtrTo(t)))
+			if err := session.NoCascade().In(pkCol.Name, missed).find(ctx, slicePtr.Interface()); err != nil {
+				return err
+			}
+
+			sliceValue := slicePtr.Elem()
+			for i := 0; i < sliceValue.Len(); i++ {
+				bean := sliceValue.Index(i)
+				elem := bean.Elem()
+				fieldValue, err := pkCol.ValueOfV(&elem)
+				if err != nil {
+					return err
+				}
+				loaded[relationKey(fieldValue.Interface())] = elem
+
+				if cacher != nil {
+					sid, err := core.PK{fieldValue.Interface()}.ToString()
+					if err != nil {
+						return err
+					}
+					cacher.PutBean(tableName, sid, bean.Interface())
+				}
+			}
+		}
+
+		for key, fields := range ct.fields {
+			bean, ok := loaded[key]
+			if !ok {
+				return errors.New("cascade obj is not exist")
+			}
+			for _, field := range fields {
+				field.Set(bean)
+			}
+		}
+	}
+	return nil
+}
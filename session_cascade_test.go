@@ -0,0 +1,120 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type CascadeGroup struct {
+	Id   int64
+	Name string
+}
+
+type CascadeMember struct {
+	Id    int64
+	Name  string
+	Group CascadeGroup `xorm:"group_id"`
+}
+
+func TestFindCascade(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(CascadeGroup), new(CascadeMember))
+
+	ctx := context.Background()
+	groups := []CascadeGroup{{Name: "g1"}, {Name: "g2"}}
+	for i := range groups {
+		_, err := testEngine.Insert(ctx, &groups[i])
+		assert.NoError(t, err)
+	}
+
+	members := []CascadeMember{
+		{Name: "m1", Group: groups[0]},
+		{Name: "m2", Group: groups[1]},
+		{Name: "m3", Group: groups[0]},
+		{Name: "m4"},
+	}
+	for i := range members {
+		_, err := testEngine.Insert(ctx, &members[i])
+		assert.NoError(t, err)
+	}
+
+	var found []CascadeMember
+	assert.NoError(t, testEngine.Asc("id").Find(ctx, &found))
+	assert.EqualValues(t, 4, len(found))
+	assert.EqualValues(t, "g1", found[0].Group.Name)
+	assert.EqualValues(t, "g2", found[1].Group.Name)
+	assert.EqualValues(t, "g1", found[2].Group.Name)
+	assert.EqualValues(t, 0, found[3].Group.Id)
+
+	var foundPtrs []*CascadeMember
+	assert.NoError(t, testEngine.Asc("id").Find(ctx, &foundPtrs))
+	assert.EqualValues(t, 4, len(foundPtrs))
+	assert.EqualValues(t, "g2", foundPtrs[1].Group.Name)
+
+	var member CascadeMember
+	has, err := testEngine.ID(members[1].Id).Get(ctx, &member)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "g2", member.Group.Name)
+
+	// the cascades are queried after finding, the count keeps the conditions
+	found = nil
+	total, err := testEngine.Where("name <> ?", "m2").Asc("id").Limit(2).FindAndCount(ctx, &found)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+	if assert.EqualValues(t, 2, len(found)) {
+		assert.EqualValues(t, "g1", found[0].Group.Name)
+		assert.EqualValues(t, "g1", found[1].Group.Name)
+	}
+
+	// every batch is found by the conditions after the cascades of the last one
+	var names []string
+	err = testEngine.Where("name <> ?", "m4").Asc("id").BufferSize(1).Iterate(ctx, new(CascadeMember), func(i int, bean interface{}) error {
+		names = append(names, bean.(*CascadeMember).Group.Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"g1", "g2", "g1"}, names)
+}
+
+type CascadeShelf struct {
+	Room  string `xorm:"pk varchar(20)"`
+	Shelf int    `xorm:"pk"`
+	Label string
+}
+
+type CascadeBook struct {
+	Id    int64
+	Title string
+	Shelf CascadeShelf `xorm:"shelf_id"`
+}
+
+func TestCascadeCompositeKey(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(CascadeShelf), new(CascadeBook))
+
+	ctx := context.Background()
+	shelf := CascadeShelf{Room: "a", Shelf: 1, Label: "a1"}
+	_, err := testEngine.Insert(ctx, &shelf)
+	assert.NoError(t, err)
+
+	// the composite primary keys could not be stored in one column
+	_, err = testEngine.Insert(ctx, &CascadeBook{Title: "t1", Shelf: shelf})
+	assert.EqualValues(t, ErrCompositeCascade, err)
+
+	book := CascadeBook{Title: "t1"}
+	_, err = testEngine.Insert(ctx, &book)
+	assert.NoError(t, err)
+
+	_, err = testEngine.ID(book.Id).Update(ctx, &CascadeBook{Shelf: shelf})
+	assert.EqualValues(t, ErrCompositeCascade, err)
+
+	_, err = testEngine.Count(ctx, &CascadeBook{Shelf: shelf})
+	assert.EqualValues(t, ErrCompositeCascade, err)
+}
@@ -64,6 +64,19 @@ func (session *Session) NotIn(column string, args ...interface{}) *Session {
 	return session
 }
 
+// Exists provides a query string like "EXISTS (subquery)", the subquery is a
+// *Session or a *builder.Builder
+func (session *Session) Exists(sub interface{}) *Session {
+	session.statement.Exists(sub)
+	return session
+}
+
+// NotExists provides a query string like "NOT EXISTS (subquery)"
+func (session *Session) NotExists(sub interface{}) *Session {
+	session.statement.NotExists(sub)
+	return session
+}
+
 // Conds returns session query conditions except auto bean conditions
 func (session *Session) Conds() builder.Cond {
 	return session.statement.cond
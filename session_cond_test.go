@@ -9,8 +9,10 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/go-xorm/builder"
+	"github.com/lingochamp/core"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -267,6 +269,143 @@ func TestIn(t *testing.T) {
 	}
 }
 
+func TestInExceedBindParams(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(Userinfo))
+
+	dialect, ok := testEngine.Dialect().(bindParamsDialect)
+	if !ok {
+		t.Skip("dialect has no limit of the parameters")
+	}
+	maxParams := dialect.MaxBindParams()
+
+	ctx := context.Background()
+	cnt, err := testEngine.Insert(ctx, []Userinfo{{Username: "in1"}, {Username: "in2"}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	var ids = make([]int64, maxParams+10)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	var names = make([]string, maxParams+10)
+	for i := range names {
+		names[i] = fmt.Sprintf("in%d", i+1)
+	}
+
+	var users []Userinfo
+	assert.NoError(t, testEngine.In("id", ids[:maxParams]).Find(ctx, &users))
+	assert.EqualValues(t, 2, len(users))
+
+	if testEngine.Dialect().DBType() != core.POSTGRES {
+		// the IN lists are split into chunks of bound parameters, which
+		// still exceed the max of the statement
+		_, err = testEngine.In("id", ids).Count(ctx, new(Userinfo))
+		assert.Equal(t, ErrTooManyParams, err)
+		_, err = testEngine.Where("username <> ?", "in1").In("id", ids[:maxParams]).Count(ctx, new(Userinfo))
+		assert.Equal(t, ErrTooManyParams, err)
+		return
+	}
+
+	// Postgres binds the longer lists as one array
+	users = nil
+	assert.NoError(t, testEngine.In("id", ids).Find(ctx, &users))
+	assert.EqualValues(t, 2, len(users))
+
+	users = nil
+	assert.NoError(t, testEngine.In("username", names).Find(ctx, &users))
+	assert.EqualValues(t, 2, len(users))
+
+	users = nil
+	assert.NoError(t, testEngine.NotIn("id", ids).Find(ctx, &users))
+	assert.EqualValues(t, 0, len(users))
+
+	// the other parameters of the query count
+	users = nil
+	assert.NoError(t, testEngine.Where("username <> ?", "in1").In("id", ids[:maxParams]).Find(ctx, &users))
+	assert.EqualValues(t, 1, len(users))
+
+	var times = make([]time.Time, maxParams+10)
+	_, err = testEngine.In("created", times).Count(ctx, new(Userinfo))
+	assert.Equal(t, ErrTooManyParams, err)
+}
+
+type SubQueryUser struct {
+	Id   int64
+	Name string
+	Age  int
+}
+
+type SubQueryOrder struct {
+	Id     int64
+	UserId int64
+	Amount int
+}
+
+func TestSubQuery(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(SubQueryUser), new(SubQueryOrder))
+
+	ctx := context.Background()
+	users := []SubQueryUser{{Name: "a", Age: 20}, {Name: "b", Age: 30}, {Name: "c", Age: 40}}
+	for i := range users {
+		_, err := testEngine.InsertOne(ctx, &users[i])
+		assert.NoError(t, err)
+	}
+	cnt, err := testEngine.Insert(ctx, []SubQueryOrder{
+		{UserId: users[0].Id, Amount: 5},
+		{UserId: users[1].Id, Amount: 10},
+		{UserId: users[2].Id, Amount: 20},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, cnt)
+
+	tableMapper := testEngine.GetTableMapper()
+	colMapper := testEngine.GetColumnMapper()
+	userTable := tableMapper.Obj2Table("SubQueryUser")
+	orderTable := tableMapper.Obj2Table("SubQueryOrder")
+	userID := colMapper.Obj2Table("UserId")
+
+	// the args of the subquery are between the args of the outer conditions
+	var found []SubQueryUser
+	sub := testEngine.Table(orderTable).Cols(userID).Where("amount > ?", 8)
+	err = testEngine.Where("age > ?", 25).In("id", sub).Where("name <> ?", "c").Find(ctx, &found)
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(found)) {
+		assert.EqualValues(t, "b", found[0].Name)
+	}
+
+	found = nil
+	err = testEngine.NotIn("id", builder.Select(userID).From(orderTable).Where(builder.Gt{"amount": 8})).Find(ctx, &found)
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(found)) {
+		assert.EqualValues(t, "a", found[0].Name)
+	}
+
+	join := fmt.Sprintf("%s.%s = %s.id", testEngine.Quote(orderTable), testEngine.Quote(userID), testEngine.Quote(userTable))
+	exists := builder.Select("*").From(orderTable).Where(builder.Expr(join).And(builder.Gte{"amount": 10}))
+	total, err := testEngine.Exists(exists).Count(ctx, new(SubQueryUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+
+	total, err = testEngine.NotExists(exists).Count(ctx, new(SubQueryUser))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+
+	// derived table
+	derived := testEngine.Table(userTable).Where("age > ?", 25)
+	found = nil
+	err = testEngine.Table(derived).Alias("t").Where("t.age < ?", 35).Find(ctx, &found)
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(found)) {
+		assert.EqualValues(t, "b", found[0].Name)
+	}
+
+	total, err = testEngine.Table(derived).Alias("t").Count(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+}
+
 func TestFindAndCount(t *testing.T) {
 	assert.NoError(t, prepareEngine())
 
@@ -297,4 +436,41 @@ func TestFindAndCount(t *testing.T) {
 	total, err := testEngine.Where(conds).Count(context.Background(), new(FindAndCount))
 	assert.NoError(t, err)
 	assert.EqualValues(t, 1, total)
+
+	_, err = testEngine.Insert(context.Background(), &FindAndCount{Name: "test2"})
+	assert.NoError(t, err)
+
+	results = nil
+	total, err = testEngine.Where("name <> ?", "test3").Desc("id").Limit(1).FindAndCount(context.Background(), &results)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+	if assert.EqualValues(t, 1, len(results)) {
+		assert.EqualValues(t, 3, results[0].Id)
+	}
+
+	results = nil
+	total, err = testEngine.FindAndCount(context.Background(), &results, &FindAndCount{Name: "test2"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	assert.EqualValues(t, 2, len(results))
+
+	// the groups and the distinct rows are counted
+	var names []string
+	total, err = testEngine.Table(new(FindAndCount)).Cols("name").GroupBy("name").Limit(1).FindAndCount(context.Background(), &names)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	assert.EqualValues(t, 1, len(names))
+
+	results = nil
+	total, err = testEngine.Distinct("name").Limit(1).FindAndCount(context.Background(), &results)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	assert.EqualValues(t, 1, len(results))
+
+	// the rows of a selected expression are counted, not the expression
+	names = nil
+	total, err = testEngine.Table(new(FindAndCount)).Select("name").Limit(1).FindAndCount(context.Background(), &names)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+	assert.EqualValues(t, 1, len(names))
 }
@@ -212,7 +212,7 @@ func (session *Session) bytes2Value(ctx context.Context, col *core.Column, field
 
 				// TODO: current only support 1 primary key
 				if len(table.PrimaryKeys) > 1 {
-					return errors.New("unsupported composited primary key cascade")
+					return ErrCompositeCascade
 				}
 
 				var pk = make(core.PK, len(table.PrimaryKeys))
@@ -494,7 +494,7 @@ func (session *Session) bytes2Value(ctx context.Context, col *core.Column, field
 					}
 
 					if len(table.PrimaryKeys) > 1 {
-						return errors.New("unsupported composited primary key cascade")
+						return ErrCompositeCascade
 					}
 
 					var pk = make(core.PK, len(table.PrimaryKeys))
@@ -600,6 +600,8 @@ func (session *Session) value2Interface(col *core.Column, fieldValue reflect.Val
 			if len(fieldTable.PrimaryKeys) == 1 {
 				pkField := reflect.Indirect(fieldValue).FieldByName(fieldTable.PKColumns()[0].FieldName)
 				return pkField.Interface(), nil
+			} else if len(fieldTable.PrimaryKeys) > 1 {
+				return nil, ErrCompositeCascade
 			}
 			return 0, fmt.Errorf("no primary key for col %v", col.Name)
 		}
@@ -6,9 +6,11 @@ package xorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/lingochamp/core"
 )
@@ -144,10 +146,22 @@ func (session *Session) Delete(ctx context.Context, bean interface{}) (int64, er
 		}
 	}
 
+	var isReturning = len(session.statement.returning) > 0
+	var isSoftDelete = !session.statement.unscoped && table.DeletedColumn() != nil
+	var pseudoTable = "DELETED"
+	if isSoftDelete {
+		pseudoTable = "INSERTED"
+	}
+	output, returning, err := session.statement.genReturning(pseudoTable)
+	if err != nil {
+		return 0, err
+	}
+
 	var realSQL string
 	argsForCache := make([]interface{}, 0, len(condArgs)*2)
-	if session.statement.unscoped || table.DeletedColumn() == nil { // tag "deleted" is disabled
-		realSQL = deleteSQL
+	if !isSoftDelete { // tag "deleted" is disabled
+		realSQL = fmt.Sprintf("DELETE FROM %v%v", tableName, output) +
+			strings.TrimPrefix(deleteSQL, "DELETE FROM "+tableName)
 		copy(argsForCache, condArgs)
 		argsForCache = append(condArgs, argsForCache...)
 	} else {
@@ -156,9 +170,10 @@ func (session *Session) Delete(ctx context.Context, bean interface{}) (int64, er
 		argsForCache = append(condArgs, argsForCache...)
 
 		deletedColumn := table.DeletedColumn()
-		realSQL = fmt.Sprintf("UPDATE %v SET %v = ? WHERE %v",
+		realSQL = fmt.Sprintf("UPDATE %v SET %v = ?%v WHERE %v",
 			session.engine.Quote(session.statement.TableName()),
 			session.engine.Quote(deletedColumn.Name),
+			output,
 			condSQL)
 
 		if len(orderSQL) > 0 {
@@ -205,7 +220,17 @@ func (session *Session) Delete(ctx context.Context, bean interface{}) (int64, er
 	}
 
 	session.statement.RefTable = table
-	res, err := session.exec(ctx, realSQL, condArgs...)
+	realSQL += returning
+
+	var affected int64
+	if isReturning {
+		affected, err = session.queryReturningBean(ctx, bean, realSQL, condArgs...)
+	} else {
+		var res sql.Result
+		if res, err = session.exec(ctx, realSQL, condArgs...); err == nil {
+			affected, err = res.RowsAffected()
+		}
+	}
 	if err != nil {
 		return 0, err
 	}
@@ -237,5 +262,5 @@ func (session *Session) Delete(ctx context.Context, bean interface{}) (int64, er
 	cleanupProcessorsClosures(&session.afterClosures)
 	// --
 
-	return res.RowsAffected()
+	return affected, nil
 }
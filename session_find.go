@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
@@ -27,13 +28,90 @@ func (session *Session) Find(ctx context.Context, rowsSlicePtr interface{}, cond
 	if session.isAutoClose {
 		defer session.Close()
 	}
-	return session.find(ctx, rowsSlicePtr, condiBean...)
+
+	// the statement is reset after querying
+	var preloads = session.statement.preloads
+	if err := session.find(ctx, rowsSlicePtr, condiBean...); err != nil || len(preloads) == 0 {
+		return err
+	}
+	return session.preloadSlice(ctx, rowsSlicePtr, preloads)
+}
+
+// FindAndCount finds the records like Find and returns the total count of the
+// records by the same conditions, which ignores Limit and OrderBy. The records
+// of GROUP BY, DISTINCT or the raw SQL are counted by a subquery.
+func (session *Session) FindAndCount(ctx context.Context, rowsSlicePtr interface{}, condiBean ...interface{}) (int64, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	// the statement is reset after querying
+	var preloads = session.statement.preloads
+	total, err := session.findAndCount(ctx, rowsSlicePtr, condiBean...)
+	if err != nil || len(preloads) == 0 {
+		return total, err
+	}
+	return total, session.preloadSlice(ctx, rowsSlicePtr, preloads)
+}
+
+func (session *Session) findAndCount(ctx context.Context, rowsSlicePtr interface{}, condiBean ...interface{}) (int64, error) {
+	defer session.resetStatement()
+
+	sliceValue, err := session.findConds(rowsSlicePtr, condiBean...)
+	if err != nil {
+		return 0, err
+	}
+	// the count is built from a copy of the statement with the conditions of
+	// condiBean and the deleted column, the statement is reset by finding and
+	// the cascades and the cacher query the related tables by it
+	var count = session.statement
+	if err := session.findRows(ctx, rowsSlicePtr, sliceValue); err != nil {
+		return 0, err
+	}
+
+	session.statement = count
+	var statement = &session.statement
+	statement.LimitN, statement.Start, statement.OrderStr = 0, 0, ""
+	// the rows of the selected expressions, the groups and the distinct rows
+	// are counted by a derived table, and the CTEs are kept on the count
+	if statement.RawSQL != "" || statement.selectStr != "" || statement.GroupByStr != "" || statement.IsDistinct {
+		var ctes = statement.ctes
+		statement.ctes = nil
+		sqlStr, args, err := session.genQuerySQL()
+		if err != nil {
+			return 0, err
+		}
+		statement.Init()
+		statement.ctes = ctes
+		statement.derived = &subQuery{sql: sqlStr, args: args}
+		statement.TableAlias = "xorm_count"
+	}
+
+	sqlStr, args, err := statement.genCountSQL()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	if err := session.queryRow(ctx, sqlStr, args...).Scan(&total); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return total, nil
 }
 
 func (session *Session) find(ctx context.Context, rowsSlicePtr interface{}, condiBean ...interface{}) error {
+	sliceValue, err := session.findConds(rowsSlicePtr, condiBean...)
+	if err != nil {
+		return err
+	}
+	return session.findRows(ctx, rowsSlicePtr, sliceValue)
+}
+
+// findConds sets the table of the slice elements and adds the conditions of
+// condiBean and the deleted column to the statement
+func (session *Session) findConds(rowsSlicePtr interface{}, condiBean ...interface{}) (reflect.Value, error) {
 	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
 	if sliceValue.Kind() != reflect.Slice && sliceValue.Kind() != reflect.Map {
-		return errors.New("needs a pointer to a slice or a map")
+		return sliceValue, errors.New("needs a pointer to a slice or a map")
 	}
 
 	sliceElementType := sliceValue.Type().Elem()
@@ -44,7 +122,7 @@ func (session *Session) find(ctx context.Context, rowsSlicePtr interface{}, cond
 			if sliceElementType.Elem().Kind() == reflect.Struct {
 				pv := reflect.New(sliceElementType.Elem())
 				if err := session.statement.setRefValue(pv.Elem()); err != nil {
-					return err
+					return sliceValue, err
 				}
 			} else {
 				tp = tpNonStruct
@@ -52,7 +130,7 @@ func (session *Session) find(ctx context.Context, rowsSlicePtr interface{}, cond
 		} else if sliceElementType.Kind() == reflect.Struct {
 			pv := reflect.New(sliceElementType)
 			if err := session.statement.setRefValue(pv.Elem()); err != nil {
-				return err
+				return sliceValue, err
 			}
 		} else {
 			tp = tpNonStruct
@@ -68,7 +146,7 @@ func (session *Session) find(ctx context.Context, rowsSlicePtr interface{}, cond
 			var err error
 			autoCond, err = session.statement.buildConds(table, condiBean[0], true, true, false, true, addedTableName)
 			if err != nil {
-				return err
+				return sliceValue, err
 			}
 		} else {
 			// !oinume! Add "<col> IS NULL" to WHERE whatever condiBean is given.
@@ -88,6 +166,14 @@ func (session *Session) find(ctx context.Context, rowsSlicePtr interface{}, cond
 		}
 	}
 
+	session.statement.cond = session.statement.cond.And(autoCond)
+	return sliceValue, nil
+}
+
+func (session *Session) findRows(ctx context.Context, rowsSlicePtr interface{}, sliceValue reflect.Value) error {
+	var sliceElementType = sliceValue.Type().Elem()
+	var table = session.statement.RefTable
+
 	var sqlStr string
 	var args []interface{}
 	var err error
@@ -122,13 +208,12 @@ func (session *Session) find(ctx context.Context, rowsSlicePtr interface{}, cond
 			}
 		}
 
-		session.statement.cond = session.statement.cond.And(autoCond)
 		condSQL, condArgs, err := builder.ToSQL(session.statement.cond)
 		if err != nil {
 			return err
 		}
 
-		args = append(session.statement.joinArgs, condArgs...)
+		args = session.statement.selectArgs(condArgs)
 		sqlStr, err = session.statement.genSelectSQL(columnStr, condSQL)
 		if err != nil {
 			return err
@@ -240,11 +325,18 @@ func (session *Session) noCacheFind(ctx context.Context, table *core.Table, cont
 		if err != nil {
 			return err
 		}
+		// load the cascade fields of all the beans after the rows are closed
+		var batch = newCascadeBatch()
+		session.cascadeBatch = batch
 		err = session.rows2Beans(ctx, rows, fields, tb, newElemFunc, containerValueSetFunc)
+		session.cascadeBatch = nil
 		rows.Close()
 		if err != nil {
 			return err
 		}
+		if err = session.loadCascades(ctx, batch); err != nil {
+			return err
+		}
 		return session.executeProcessors()
 	}
 
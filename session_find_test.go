@@ -489,3 +489,141 @@ func TestFindBit(t *testing.T) {
 	assert.NoError(t, err)
 	assert.EqualValues(t, 2, len(results))
 }
+
+type CteCategory struct {
+	Id       int64
+	ParentId int64
+	Name     string
+}
+
+func TestFindWith(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(CteCategory))
+
+	ctx := context.Background()
+	root := CteCategory{Name: "root"}
+	_, err := testEngine.InsertOne(ctx, &root)
+	assert.NoError(t, err)
+	child := CteCategory{ParentId: root.Id, Name: "child"}
+	_, err = testEngine.InsertOne(ctx, &child)
+	assert.NoError(t, err)
+	cnt, err := testEngine.Insert(ctx, []CteCategory{
+		{ParentId: child.Id, Name: "grandchild"},
+		{Name: "other"},
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	table := testEngine.Quote(testEngine.GetTableMapper().Obj2Table("CteCategory"))
+	parentID := testEngine.Quote(testEngine.GetColumnMapper().Obj2Table("ParentId"))
+	tree := fmt.Sprintf("SELECT * FROM %s WHERE id = ? UNION ALL SELECT c.* FROM %s c JOIN tree ON c.%s = tree.id",
+		table, table, parentID)
+
+	var categories []CteCategory
+	err = testEngine.WithRecursive("tree", tree, root.Id).Table("tree").Where("name <> ?", "child").Asc("id").Find(ctx, &categories)
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 2, len(categories)) {
+		assert.EqualValues(t, "root", categories[0].Name)
+		assert.EqualValues(t, "grandchild", categories[1].Name)
+	}
+
+	var names []string
+	err = testEngine.WithRecursive("tree", tree, root.Id).Table("tree").Iterate(ctx, new(CteCategory), func(i int, bean interface{}) error {
+		names = append(names, bean.(*CteCategory).Name)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, len(names))
+
+	roots := testEngine.Table(new(CteCategory)).Where(fmt.Sprintf("%s = ?", parentID), 0)
+	total, err := testEngine.With("roots", roots).Table("roots").Count(ctx)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+
+	// the args of the CTE are bound once when paged by an offset
+	categories = nil
+	err = testEngine.WithRecursive("tree", tree, root.Id).Table("tree").Asc("id").Limit(1, 1).Find(ctx, &categories)
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(categories)) {
+		assert.EqualValues(t, "child", categories[0].Name)
+	}
+
+	categories = nil
+	total, err = testEngine.WithRecursive("tree", tree, root.Id).Table("tree").Distinct("name").Limit(1).FindAndCount(ctx, &categories)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+	assert.EqualValues(t, 1, len(categories))
+}
+
+type UnionLive struct {
+	Id    int64
+	Name  string
+	Score int
+}
+
+type UnionArchive struct {
+	Id    int64
+	Name  string
+	Score int
+}
+
+func TestFindUnion(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(UnionLive), new(UnionArchive))
+
+	ctx := context.Background()
+	cnt, err := testEngine.Insert(ctx, []UnionLive{{Name: "a", Score: 10}, {Name: "b", Score: 20}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	cnt, err = testEngine.Insert(ctx, []UnionArchive{{Name: "b", Score: 20}, {Name: "c", Score: 30}})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+
+	archive := func() *Session {
+		return testEngine.Table(new(UnionArchive)).Cols("name", "score").Where("score > ?", 0)
+	}
+	names := func(lives []UnionLive) []string {
+		var names []string
+		for _, live := range lives {
+			names = append(names, live.Name)
+		}
+		return names
+	}
+
+	var lives []UnionLive
+	err = testEngine.Cols("name", "score").Where("score < ?", 100).UnionAll(archive()).Desc("score").Find(ctx, &lives)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"c", "b", "b", "a"}, names(lives))
+
+	lives = nil
+	err = testEngine.Cols("name", "score").Union(archive()).Desc("score").Find(ctx, &lives)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"c", "b", "a"}, names(lives))
+
+	lives = nil
+	err = testEngine.Cols("name", "score").Union(archive()).Desc("score").Limit(2, 1).Find(ctx, &lives)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"b", "a"}, names(lives))
+
+	total, err := testEngine.Cols("name", "score").Union(archive()).Count(ctx, new(UnionLive))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, total)
+
+	lives = nil
+	assert.Error(t, testEngine.Cols("name", "score").Union(nil).Find(ctx, &lives))
+
+	if testEngine.Dialect().DBType() == core.MYSQL {
+		// INTERSECT and EXCEPT are supported since MySQL 8.0.31
+		return
+	}
+
+	lives = nil
+	err = testEngine.Cols("name", "score").Intersect(archive()).Find(ctx, &lives)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"b"}, names(lives))
+
+	lives = nil
+	err = testEngine.Cols("name", "score").Except(archive()).Find(ctx, &lives)
+	assert.NoError(t, err)
+	assert.EqualValues(t, []string{"a"}, names(lives))
+}
@@ -19,7 +19,19 @@ func (session *Session) Get(ctx context.Context, bean interface{}) (bool, error)
 	if session.isAutoClose {
 		defer session.Close()
 	}
-	return session.get(ctx, bean)
+
+	// the statement is reset after querying
+	var preloads = session.statement.preloads
+	has, err := session.get(ctx, bean)
+	if err != nil || !has || len(preloads) == 0 {
+		return has, err
+	}
+
+	beanValue := reflect.Indirect(reflect.ValueOf(bean))
+	if beanValue.Kind() != reflect.Struct {
+		return has, errors.New("Preload needs a struct")
+	}
+	return has, session.preload(ctx, []reflect.Value{beanValue}, newPreloadTree(preloads))
 }
 
 func (session *Session) get(ctx context.Context, bean interface{}) (bool, error) {
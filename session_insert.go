@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
@@ -57,7 +58,29 @@ func (session *Session) Insert(ctx context.Context, beans ...interface{}) (int64
 	return affected, err
 }
 
-func (session *Session) innerInsertMulti(ctx context.Context, rowsSlicePtr interface{}) (int64, error) {
+// OnConflict makes the insert an upsert, when the inserted record conflicts
+// with an existing one on the columns (default the primary keys), the existing
+// record will be updated with the inserted values. MySQL ignores the columns
+// and uses all the unique indexes.
+func (session *Session) OnConflict(columns ...string) *Session {
+	session.statement.OnConflict(columns...)
+	return session
+}
+
+// DoUpdate sets the columns to update on conflict, default all the inserted
+// columns except the conflict and the created columns
+func (session *Session) DoUpdate(columns ...string) *Session {
+	session.statement.DoUpdate(columns...)
+	return session
+}
+
+// DoNothing keeps the existing record unchanged on conflict
+func (session *Session) DoNothing() *Session {
+	session.statement.DoNothing()
+	return session
+}
+
+func (session *Session) innerInsertMulti(ctx context.Context, rowsSlicePtr interface{}) (_ int64, err error) {
 	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
 	if sliceValue.Kind() != reflect.Slice {
 		return 0, errors.New("needs a pointer to a slice")
@@ -80,6 +103,7 @@ func (session *Session) innerInsertMulti(ctx context.Context, rowsSlicePtr inter
 
 	var colNames []string
 	var colMultiPlaces []string
+	var rowPlaces [][]string
 	var args []interface{}
 	var cols []*core.Column
 
@@ -209,38 +233,123 @@ func (session *Session) innerInsertMulti(ctx context.Context, rowsSlicePtr inter
 			}
 		}
 		colMultiPlaces = append(colMultiPlaces, strings.Join(colPlaces, ", "))
+		rowPlaces = append(rowPlaces, colPlaces)
 	}
 	cleanupProcessorsClosures(&session.beforeClosures)
 
-	var sql = "INSERT INTO %s (%v%v%v) VALUES (%v)"
-	var statement string
-	var tableName = session.statement.TableName()
-	if session.engine.dialect.DBType() == core.ORACLE {
-		sql = "INSERT ALL INTO %s (%v%v%v) VALUES (%v) SELECT 1 FROM DUAL"
-		temp := fmt.Sprintf(") INTO %s (%v%v%v) VALUES (",
-			session.engine.Quote(tableName),
-			session.engine.QuoteStr(),
-			strings.Join(colNames, session.engine.QuoteStr()+", "+session.engine.QuoteStr()),
-			session.engine.QuoteStr())
-		statement = fmt.Sprintf(sql,
-			session.engine.Quote(tableName),
-			session.engine.QuoteStr(),
-			strings.Join(colNames, session.engine.QuoteStr()+", "+session.engine.QuoteStr()),
-			session.engine.QuoteStr(),
-			strings.Join(colMultiPlaces, temp))
-	} else {
-		statement = fmt.Sprintf(sql,
-			session.engine.Quote(tableName),
-			session.engine.QuoteStr(),
-			strings.Join(colNames, session.engine.QuoteStr()+", "+session.engine.QuoteStr()),
-			session.engine.QuoteStr(),
-			strings.Join(colMultiPlaces, "),("))
+	// return the autoincrement column too so that the ids could be filled
+	var isReturning = len(session.statement.returning) > 0
+	if isReturning && len(table.AutoIncrement) > 0 &&
+		!containsColumn(session.statement.returning, "*") &&
+		!containsColumn(session.statement.returning, table.AutoIncrement) {
+		session.statement.Returning(table.AutoIncrement)
 	}
-	res, err := session.exec(ctx, statement, args...)
+	output, returning, err := session.statement.genReturning("INSERTED")
 	if err != nil {
 		return 0, err
 	}
 
+	var tableName = session.statement.TableName()
+	var genSQL = func(rowPlaces [][]string, colMultiPlaces []string) (string, error) {
+		var sql = "INSERT INTO %s (%v%v%v)%v VALUES (%v)"
+		var statement string
+		if session.statement.conflict != nil {
+			if statement, err = session.statement.genUpsertSQL(colNames, rowPlaces); err != nil {
+				return "", err
+			}
+			if len(output) > 0 {
+				statement = strings.TrimSuffix(statement, ";") + output + ";"
+			}
+		} else if session.engine.dialect.DBType() == core.ORACLE {
+			sql = "INSERT ALL INTO %s (%v%v%v) VALUES (%v) SELECT 1 FROM DUAL"
+			temp := fmt.Sprintf(") INTO %s (%v%v%v) VALUES (",
+				session.engine.Quote(tableName),
+				session.engine.QuoteStr(),
+				strings.Join(colNames, session.engine.QuoteStr()+", "+session.engine.QuoteStr()),
+				session.engine.QuoteStr())
+			statement = fmt.Sprintf(sql,
+				session.engine.Quote(tableName),
+				session.engine.QuoteStr(),
+				strings.Join(colNames, session.engine.QuoteStr()+", "+session.engine.QuoteStr()),
+				session.engine.QuoteStr(),
+				strings.Join(colMultiPlaces, temp))
+		} else {
+			statement = fmt.Sprintf(sql,
+				session.engine.Quote(tableName),
+				session.engine.QuoteStr(),
+				strings.Join(colNames, session.engine.QuoteStr()+", "+session.engine.QuoteStr()),
+				session.engine.QuoteStr(),
+				output,
+				strings.Join(colMultiPlaces, "),("))
+		}
+		return statement + returning, nil
+	}
+
+	// split the rows into several statements so that none of them has more
+	// parameters than the dialect allows
+	var rowsPerStmt = size
+	if maxParams := session.engine.maxBindParams(); maxParams > 0 && len(colNames) > 0 {
+		if n := maxParams / len(colNames); n < rowsPerStmt {
+			rowsPerStmt = n
+		}
+		if rowsPerStmt < 1 {
+			rowsPerStmt = 1
+		}
+	}
+
+	var statements []string
+	for start := 0; start < size; start += rowsPerStmt {
+		end := start + rowsPerStmt
+		if end > size {
+			end = size
+		}
+		statement, err := genSQL(rowPlaces[start:end], colMultiPlaces[start:end])
+		if err != nil {
+			return 0, err
+		}
+		statements = append(statements, statement)
+	}
+
+	// insert all the rows or none of them
+	if len(statements) > 1 {
+		var endTx func(*error)
+		if endTx, err = session.beginImplicitTx(ctx); err != nil {
+			return 0, err
+		}
+		defer endTx(&err)
+	}
+
+	var affected int64
+	var beans []interface{}
+	if isReturning {
+		beans = sliceElemPtrs(sliceValue)
+	}
+	for i, statement := range statements {
+		var start = i * rowsPerStmt
+		var end = start + rowsPerStmt
+		if end > size {
+			end = size
+		}
+		var stmtArgs = args[start*len(colNames) : end*len(colNames)]
+
+		// the statement has been reset by the last insert
+		session.statement.RefTable = table
+
+		var cnt int64
+		if isReturning {
+			cnt, err = session.queryReturning(ctx, beans[start:end], statement, stmtArgs...)
+		} else {
+			var res sql.Result
+			if res, err = session.exec(ctx, statement, stmtArgs...); err == nil {
+				cnt, err = res.RowsAffected()
+			}
+		}
+		affected += cnt
+		if err != nil {
+			return affected, err
+		}
+	}
+
 	if cacher := session.engine.getCacher2(table); cacher != nil && session.statement.UseCache {
 		session.cacheInsert(table, tableName)
 	}
@@ -276,7 +385,7 @@ func (session *Session) innerInsertMulti(ctx context.Context, rowsSlicePtr inter
 	}
 
 	cleanupProcessorsClosures(&session.afterClosures)
-	return res.RowsAffected()
+	return affected, nil
 }
 
 // InsertMulti insert multiple records
@@ -348,22 +457,49 @@ func (session *Session) innerInsert(ctx context.Context, bean interface{}) (int6
 		}
 	}
 
+	// return the autoincrement column too so that the id could be filled
+	var isReturning = len(session.statement.returning) > 0
+	if isReturning && len(table.AutoIncrement) > 0 &&
+		!containsColumn(session.statement.returning, "*") &&
+		!containsColumn(session.statement.returning, table.AutoIncrement) {
+		session.statement.Returning(table.AutoIncrement)
+	}
+	output, returning, err := session.statement.genReturning("INSERTED")
+	if err != nil {
+		return 0, err
+	}
+
 	var sqlStr string
 	var tableName = session.statement.TableName()
 	if len(colPlaces) > 0 {
-		sqlStr = fmt.Sprintf("INSERT INTO %s (%v%v%v) VALUES (%v)",
+		sqlStr = fmt.Sprintf("INSERT INTO %s (%v%v%v)%s VALUES (%v)",
 			session.engine.Quote(tableName),
 			session.engine.QuoteStr(),
 			strings.Join(colNames, session.engine.Quote(", ")),
 			session.engine.QuoteStr(),
+			output,
 			colPlaces)
 	} else {
 		if session.engine.dialect.DBType() == core.MYSQL {
 			sqlStr = fmt.Sprintf("INSERT INTO %s VALUES ()", session.engine.Quote(tableName))
 		} else {
-			sqlStr = fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", session.engine.Quote(tableName))
+			sqlStr = fmt.Sprintf("INSERT INTO %s%s DEFAULT VALUES", session.engine.Quote(tableName), output)
+		}
+	}
+
+	// the version of an upserted record is unknown, it won't be set to 1
+	var isUpsert = session.statement.conflict != nil
+	if isUpsert {
+		places := append(makeArray("?", len(colNames)-len(exprColumns)), exprColVals...)
+		sqlStr, err = session.statement.genUpsertSQL(colNames, [][]string{places})
+		if err != nil {
+			return 0, err
+		}
+		if len(output) > 0 {
+			sqlStr = strings.TrimSuffix(sqlStr, ";") + output + ";"
 		}
 	}
+	sqlStr += returning
 
 	handleAfterInsertProcessorFunc := func(bean interface{}) {
 		if session.isAutoCommit {
@@ -395,7 +531,28 @@ func (session *Session) innerInsert(ctx context.Context, bean interface{}) (int6
 
 	// for postgres, many of them didn't implement lastInsertId, so we should
 	// implemented it ourself.
-	if session.engine.dialect.DBType() == core.ORACLE && len(table.AutoIncrement) > 0 {
+	if isReturning {
+		if table.Version != "" && session.statement.checkVersion && !isUpsert {
+			verValue, err := table.VersionColumn().ValueOf(bean)
+			if err != nil {
+				session.engine.logger(ctx).Error(err)
+			} else if verValue.IsValid() && verValue.CanSet() {
+				verValue.SetInt(1)
+			}
+		}
+
+		affected, err := session.queryReturning(ctx, []interface{}{bean}, sqlStr, args...)
+		if err != nil {
+			return affected, err
+		}
+
+		defer handleAfterInsertProcessorFunc(bean)
+
+		if cacher := session.engine.getCacher2(table); cacher != nil && session.statement.UseCache {
+			session.cacheInsert(table, tableName)
+		}
+		return affected, nil
+	} else if session.engine.dialect.DBType() == core.ORACLE && len(table.AutoIncrement) > 0 && !isUpsert {
 		res, err := session.queryBytes(ctx, "select seq_atable.currval from dual", args...)
 		if err != nil {
 			return 0, err
@@ -452,7 +609,12 @@ func (session *Session) innerInsert(ctx context.Context, bean interface{}) (int6
 			session.cacheInsert(table, tableName)
 		}
 
-		if table.Version != "" && session.statement.checkVersion {
+		// DO NOTHING returns no rows when the record conflicts
+		if isUpsert && len(res) < 1 {
+			return 0, nil
+		}
+
+		if table.Version != "" && session.statement.checkVersion && !isUpsert {
 			verValue, err := table.VersionColumn().ValueOf(bean)
 			if err != nil {
 				session.engine.logger(ctx).Error(err)
@@ -495,7 +657,7 @@ func (session *Session) innerInsert(ctx context.Context, bean interface{}) (int6
 			session.cacheInsert(table, tableName)
 		}
 
-		if table.Version != "" && session.statement.checkVersion {
+		if table.Version != "" && session.statement.checkVersion && !isUpsert {
 			verValue, err := table.VersionColumn().ValueOf(bean)
 			if err != nil {
 				session.engine.logger(ctx).Error(err)
@@ -508,6 +670,13 @@ func (session *Session) innerInsert(ctx context.Context, bean interface{}) (int6
 			return res.RowsAffected()
 		}
 
+		// LastInsertId is stale when nothing was upserted
+		if isUpsert {
+			if affected, err := res.RowsAffected(); err != nil || affected == 0 {
+				return affected, err
+			}
+		}
+
 		var id int64
 		id, err = res.LastInsertId()
 		if err != nil || id <= 0 {
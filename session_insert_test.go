@@ -663,3 +663,110 @@ func TestInsertCreatedInt64(t *testing.T) {
 
 	assert.EqualValues(t, data.Created, data2.Created)
 }
+
+func TestInsertOnConflict(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type UpsertStruct struct {
+		Id      int64
+		Name    string `xorm:"unique"`
+		Value   int
+		Created int64 `xorm:"created"`
+	}
+
+	assertSync(t, new(UpsertStruct))
+
+	ctx := context.Background()
+	cnt, err := testEngine.Insert(ctx, &UpsertStruct{Name: "a", Value: 1})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	_, err = testEngine.Table(new(UpsertStruct)).Where("name = ?", "a").
+		Update(ctx, map[string]interface{}{"created": 1})
+	assert.NoError(t, err)
+
+	_, err = testEngine.OnConflict("name").DoUpdate("value").Insert(ctx, &UpsertStruct{Name: "a", Value: 2})
+	assert.NoError(t, err)
+
+	var s UpsertStruct
+	has, err := testEngine.Where("name = ?", "a").Get(ctx, &s)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 2, s.Value)
+	assert.EqualValues(t, 1, s.Created)
+
+	cnt, err = testEngine.OnConflict("name").DoNothing().Insert(ctx, &UpsertStruct{Name: "a", Value: 3})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	s = UpsertStruct{}
+	has, err = testEngine.Where("name = ?", "a").Get(ctx, &s)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 2, s.Value)
+
+	_, err = testEngine.OnConflict("name").Insert(ctx, []UpsertStruct{
+		{Name: "a", Value: 4},
+		{Name: "b", Value: 5},
+	})
+	assert.NoError(t, err)
+
+	var ss []UpsertStruct
+	assert.NoError(t, testEngine.Asc("name").Find(ctx, &ss))
+	assert.EqualValues(t, 2, len(ss))
+	assert.EqualValues(t, 4, ss[0].Value)
+	assert.EqualValues(t, 1, ss[0].Created)
+	assert.EqualValues(t, 5, ss[1].Value)
+
+	_, err = testEngine.OnConflict("name").DoUpdate("not_inserted").Insert(ctx, &UpsertStruct{Name: "a"})
+	assert.Error(t, err)
+}
+
+func TestInsertMultiExceedBindParams(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type InsertMultiChunk struct {
+		Id   int64
+		Name string
+		Age  int
+	}
+
+	assertSync(t, new(InsertMultiChunk))
+
+	dialect, ok := testEngine.Dialect().(bindParamsDialect)
+	if !ok || !testEngine.Dialect().SupportInsertMany() {
+		t.Skip("dialect has no limit of the parameters")
+	}
+	maxParams := dialect.MaxBindParams()
+
+	var beans = make([]InsertMultiChunk, maxParams/2+1)
+	for i := range beans {
+		beans[i] = InsertMultiChunk{Name: fmt.Sprintf("chunk_%d", i), Age: i}
+	}
+
+	ctx := context.Background()
+	cnt, err := testEngine.InsertMulti(ctx, &beans)
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(beans), cnt)
+
+	total, err := testEngine.Count(ctx, new(InsertMultiChunk))
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(beans), total)
+
+	// the session is back to autocommit after the implicit transaction
+	session := testEngine.NewSession()
+	defer session.Close()
+	for i := range beans {
+		beans[i].Id = 0
+	}
+	cnt, err = session.InsertMulti(ctx, &beans)
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(beans), cnt)
+	cnt, err = session.Insert(ctx, &InsertMultiChunk{Name: "single"})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	total, err = session.Count(ctx, new(InsertMultiChunk))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2*len(beans)+1, total)
+}
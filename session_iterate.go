@@ -71,8 +71,25 @@ func (session *Session) bufferIterate(ctx context.Context, bean interface{}, fun
 	var start = session.statement.Start
 	v := rValue(bean)
 	sliceType := reflect.SliceOf(v.Type())
+
+	// every batch is found by a copy of the statement, which is reset by
+	// finding, and the cascades and the cacher query the related tables by it
+	defer session.resetStatement()
+	var statement = session.statement
+	var cond = statement.cond
+
+	// the batches after the first one are found by the sort keys of the last
+	// row instead of the growing offset if the order is on a unique key
+	var keys []sortKey
+	if table, err := session.engine.autoMapType(v); err == nil {
+		if keys, err = statement.sortKeys(table); err != nil || !isUniqueKeys(table, keys) {
+			keys = nil
+		}
+	}
+
 	var idx = 0
 	for {
+		session.statement = statement
 		slice := reflect.New(sliceType)
 		if err := session.Limit(bufferSize, start).find(ctx, slice.Interface(), bean); err != nil {
 			return err
@@ -85,7 +102,17 @@ func (session *Session) bufferIterate(ctx context.Context, bean interface{}, fun
 			idx++
 		}
 
-		start = start + slice.Elem().Len()
+		statement.cond = cond
+		if len(keys) > 0 && slice.Elem().Len() > 0 {
+			values, err := session.engine.keyValues(slice.Elem().Index(slice.Elem().Len()-1), keys)
+			if err != nil {
+				return err
+			}
+			statement.cond = cond.And(statement.keysetCond(keys, values, false))
+			start = 0
+		} else {
+			start = start + slice.Elem().Len()
+		}
 		if limit > 0 && idx+bufferSize > limit {
 			bufferSize = limit - idx
 		}
@@ -90,4 +90,15 @@ func TestBufferIterate(t *testing.T) {
 	})
 	assert.NoError(t, err)
 	assert.EqualValues(t, 7, cnt)
+
+	// ordered by the primary key, the batches are found by keyset
+	cnt = 0
+	err = testEngine.Where("id > ?", 2).Desc("id").BufferSize(9).Iterate(context.Background(), new(UserBufferIterate), func(i int, bean interface{}) error {
+		user := bean.(*UserBufferIterate)
+		assert.EqualValues(t, size-cnt, user.Id)
+		cnt++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.EqualValues(t, size-2, cnt)
 }
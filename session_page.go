@@ -0,0 +1,391 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/go-xorm/builder"
+	"github.com/lingochamp/core"
+)
+
+// Page is the cursors of the rows found by FindPage, which are passed to After
+// to find the next or the previous page.
+type Page struct {
+	// Next is the cursor of the next page, it's empty on the last page.
+	Next string
+	// Prev is the cursor of the previous page, it's empty on the first page.
+	Prev string
+}
+
+// pageCursor is encoded as an opaque cursor, the values are the sort keys of
+// the last row of the page, or the first row if it's backward
+type pageCursor struct {
+	Values   []json.RawMessage `json:"v"`
+	Backward bool              `json:"b,omitempty"`
+}
+
+// sortKey is a column of the ORDER BY clause
+type sortKey struct {
+	col  *core.Column
+	desc bool
+}
+
+// After sets the cursor of the page to be found by FindPage, which is the
+// Next or the Prev of the Page returned by FindPage.
+func (session *Session) After(cursor string) *Session {
+	session.statement.cursor = cursor
+	return session
+}
+
+// FindPage finds a page of the rows by keyset pagination, which filters the
+// rows by the sort keys of the last page instead of the offset. The page
+// size is set by Limit, and the rows are ordered by Asc, Desc or OrderBy on
+// the columns. The primary key is appended to the order if the columns are not
+// a unique key, so that the rows of the same sort keys are not skipped.
+func (session *Session) FindPage(ctx context.Context, rowsSlicePtr interface{}, condiBean ...interface{}) (*Page, error) {
+	if session.isAutoClose {
+		defer session.Close()
+	}
+
+	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
+	if sliceValue.Kind() != reflect.Slice {
+		return nil, errors.New("needs a pointer to a slice")
+	}
+	elemType := sliceValue.Type().Elem()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return nil, errors.New("needs a pointer to a slice of structs")
+	}
+
+	table, err := session.engine.autoMapType(reflect.New(elemType).Elem())
+	if err != nil {
+		return nil, err
+	}
+	keys, err := session.statement.sortKeys(table)
+	if err != nil {
+		return nil, err
+	}
+	if !isUniqueKeys(table, keys) {
+		if len(table.PrimaryKeys) == 0 {
+			return nil, ErrOrderNotUnique
+		}
+		keys = appendPKKeys(table, keys)
+	}
+
+	var backward bool
+	if session.statement.cursor != "" {
+		var values []interface{}
+		values, backward, err = session.decodeCursor(session.statement.cursor, elemType, keys)
+		if err != nil {
+			return nil, err
+		}
+		session.statement.cond = session.statement.cond.And(session.statement.keysetCond(keys, values, backward))
+	}
+	var hasPrev = session.statement.cursor != ""
+	var limit = session.statement.LimitN
+	session.statement.OrderStr = session.statement.keysetOrderStr(keys, backward)
+	session.statement.Start = 0
+	if limit > 0 {
+		// the extra row tells if there are more rows
+		session.statement.LimitN = limit + 1
+	}
+
+	// the statement is reset after querying
+	var preloads = session.statement.preloads
+	if err := session.find(ctx, rowsSlicePtr, condiBean...); err != nil {
+		return nil, err
+	}
+
+	var hasMore = limit > 0 && sliceValue.Len() > limit
+	if hasMore {
+		sliceValue.Set(sliceValue.Slice(0, limit))
+	}
+	if backward {
+		swap := reflect.Swapper(sliceValue.Interface())
+		for i, j := 0, sliceValue.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	var page Page
+	if sliceValue.Len() > 0 {
+		var hasNext = hasMore
+		if backward {
+			hasNext, hasPrev = true, hasMore
+		}
+		if hasNext {
+			if page.Next, err = encodeCursor(sliceValue.Index(sliceValue.Len()-1), keys, false); err != nil {
+				return nil, err
+			}
+		}
+		if hasPrev {
+			if page.Prev, err = encodeCursor(sliceValue.Index(0), keys, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(preloads) > 0 {
+		if err := session.preloadSlice(ctx, rowsSlicePtr, preloads); err != nil {
+			return nil, err
+		}
+	}
+	return &page, nil
+}
+
+// sortKeys parses the ORDER BY clause of the statement, the clause should be
+// made of the columns of the table
+func (statement *Statement) sortKeys(table *core.Table) ([]sortKey, error) {
+	if statement.OrderStr == "" {
+		return nil, nil
+	}
+
+	var keys []sortKey
+	for _, order := range strings.Split(statement.OrderStr, ",") {
+		fields := strings.Fields(order)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("unsupported order %q for keyset pagination", order)
+		}
+
+		var key sortKey
+		if len(fields) == 2 {
+			switch strings.ToUpper(fields[1]) {
+			case "ASC":
+			case "DESC":
+				key.desc = true
+			default:
+				return nil, fmt.Errorf("unsupported order %q for keyset pagination", order)
+			}
+		}
+
+		name := fields[0]
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name = name[i+1:]
+		}
+		if key.col = table.GetColumn(strings.Trim(name, "`\"[]")); key.col == nil {
+			return nil, fmt.Errorf("order %q is not a column of %s", order, table.Name)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// isUniqueKeys returns true if the keys contain the primary key or the
+// columns of a unique index
+func isUniqueKeys(table *core.Table, keys []sortKey) bool {
+	var names = make(map[string]bool, len(keys))
+	for _, key := range keys {
+		names[strings.ToLower(key.col.Name)] = true
+	}
+	containsAll := func(cols []string) bool {
+		for _, col := range cols {
+			if !names[strings.ToLower(col)] {
+				return false
+			}
+		}
+		return len(cols) > 0
+	}
+
+	if containsAll(table.PrimaryKeys) {
+		return true
+	}
+	for _, index := range table.Indexes {
+		if index.Type == core.UniqueType && containsAll(index.Cols) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendPKKeys(table *core.Table, keys []sortKey) []sortKey {
+	for _, pk := range table.PKColumns() {
+		var found bool
+		for _, key := range keys {
+			if key.col == pk {
+				found = true
+				break
+			}
+		}
+		if !found {
+			keys = append(keys, sortKey{col: pk})
+		}
+	}
+	return keys
+}
+
+func (statement *Statement) keyColName(key sortKey) string {
+	var colName = statement.Engine.Quote(key.col.Name)
+	if statement.needTableName() {
+		var nm = statement.TableName()
+		if len(statement.TableAlias) > 0 {
+			nm = statement.TableAlias
+		}
+		colName = statement.Engine.Quote(nm) + "." + colName
+	}
+	return colName
+}
+
+// keysetOrderStr returns the ORDER BY clause of the keys, which is reversed
+// if it's backward
+func (statement *Statement) keysetOrderStr(keys []sortKey, backward bool) string {
+	var orders = make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.desc != backward {
+			orders = append(orders, statement.keyColName(key)+" DESC")
+		} else {
+			orders = append(orders, statement.keyColName(key)+" ASC")
+		}
+	}
+	return strings.Join(orders, ", ")
+}
+
+// nullsSmallest reports whether the NULLs are ordered before the other values
+// in ascending order by the database
+func nullsSmallest(dbType core.DbType) bool {
+	switch dbType {
+	case core.POSTGRES, core.ORACLE:
+		return false
+	}
+	return true
+}
+
+// keysetCond returns the condition of the rows after the values of the keys,
+// e.g. a > ? OR (a = ? AND b > ?), or before the values if it's backward.
+// The NULLs of the nullable keys are ordered as the database does.
+func (statement *Statement) keysetCond(keys []sortKey, values []interface{}, backward bool) builder.Cond {
+	var smallest = nullsSmallest(statement.Engine.dialect.DBType())
+	var conds = make([]builder.Cond, 0, len(keys))
+	for i, key := range keys {
+		var and = make([]builder.Cond, 0, i+1)
+		for j := 0; j < i; j++ {
+			colName := statement.keyColName(keys[j])
+			if values[j] == nil {
+				and = append(and, builder.IsNull{colName})
+			} else {
+				and = append(and, builder.Eq{colName: values[j]})
+			}
+		}
+
+		var colName = statement.keyColName(key)
+		var ascending = key.desc == backward
+		var nullable = key.col.Nullable && !key.col.IsPrimaryKey
+		// the NULLs are after the values of the key if they're last in the order
+		var nullsAfter = nullable && ascending != smallest
+		var after builder.Cond
+		switch {
+		case values[i] == nil && !nullsAfter:
+			after = builder.NotNull{colName}
+		case values[i] == nil:
+			// no row is after NULL by the key, they're ordered by the next keys
+			continue
+		case ascending:
+			after = builder.Gt{colName: values[i]}
+		default:
+			after = builder.Lt{colName: values[i]}
+		}
+		if nullsAfter && values[i] != nil {
+			after = builder.Or(after, builder.IsNull{colName})
+		}
+		conds = append(conds, builder.And(append(and, after)...))
+	}
+	if len(conds) == 0 {
+		return builder.Expr("1 = 0")
+	}
+	return builder.Or(conds...)
+}
+
+// keyValues returns the values of the keys of the row for the conditions
+func (engine *Engine) keyValues(row reflect.Value, keys []sortKey) ([]interface{}, error) {
+	row = reflect.Indirect(row)
+	var values = make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		fieldValue, err := key.col.ValueOfV(&row)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, engine.keyValue(key.col, fieldValue.Interface()))
+	}
+	return values, nil
+}
+
+// keyValue returns the value of the key in the conditions, which is nil if
+// it's NULL
+func (engine *Engine) keyValue(col *core.Column, v interface{}) interface{} {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil
+		}
+		return engine.keyValue(col, rv.Elem().Interface())
+	}
+	switch x := v.(type) {
+	case time.Time:
+		return engine.formatColTime(col, x)
+	case driver.Valuer:
+		if value, err := x.Value(); err == nil && value == nil {
+			return nil
+		}
+	}
+	return v
+}
+
+func encodeCursor(row reflect.Value, keys []sortKey, backward bool) (string, error) {
+	row = reflect.Indirect(row)
+	var cursor = pageCursor{Backward: backward}
+	for _, key := range keys {
+		fieldValue, err := key.col.ValueOfV(&row)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(fieldValue.Interface())
+		if err != nil {
+			return "", err
+		}
+		cursor.Values = append(cursor.Values, data)
+	}
+
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes the values of the keys into the types of the fields
+func (session *Session) decodeCursor(s string, elemType reflect.Type, keys []sortKey) ([]interface{}, bool, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || len(cursor.Values) != len(keys) {
+		return nil, false, ErrInvalidCursor
+	}
+
+	row := reflect.New(elemType).Elem()
+	var values = make([]interface{}, 0, len(keys))
+	for i, key := range keys {
+		fieldValue, err := key.col.ValueOfV(&row)
+		if err != nil {
+			return nil, false, err
+		}
+		v := reflect.New(fieldValue.Type())
+		if err := json.Unmarshal(cursor.Values[i], v.Interface()); err != nil {
+			return nil, false, ErrInvalidCursor
+		}
+		values = append(values, session.engine.keyValue(key.col, v.Elem().Interface()))
+	}
+	return values, cursor.Backward, nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type PageUser struct {
+	Id    int64
+	Name  string
+	Score int
+}
+
+func TestFindPage(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(PageUser))
+
+	ctx := context.Background()
+	var users []PageUser
+	for i := 0; i < 7; i++ {
+		users = append(users, PageUser{Name: string(rune('a' + i)), Score: i / 2})
+	}
+	cnt, err := testEngine.Insert(ctx, users)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 7, cnt)
+
+	names := func(users []PageUser) string {
+		var names string
+		for _, user := range users {
+			names += user.Name
+		}
+		return names
+	}
+
+	// the scores are not unique so the id is appended to the order
+	var page1 []PageUser
+	p1, err := testEngine.Desc("score").Limit(3).FindPage(ctx, &page1)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "gef", names(page1))
+	assert.NotEmpty(t, p1.Next)
+	assert.Empty(t, p1.Prev)
+
+	var page2 []PageUser
+	p2, err := testEngine.Desc("score").Limit(3).After(p1.Next).FindPage(ctx, &page2)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "cda", names(page2))
+	assert.NotEmpty(t, p2.Next)
+	assert.NotEmpty(t, p2.Prev)
+
+	var page3 []PageUser
+	p3, err := testEngine.Desc("score").Limit(3).After(p2.Next).FindPage(ctx, &page3)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "b", names(page3))
+	assert.Empty(t, p3.Next)
+	assert.NotEmpty(t, p3.Prev)
+
+	var prev []PageUser
+	p, err := testEngine.Desc("score").Limit(3).After(p3.Prev).FindPage(ctx, &prev)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "cda", names(prev))
+	assert.EqualValues(t, p2.Next, p.Next)
+	assert.NotEmpty(t, p.Prev)
+
+	prev = nil
+	p, err = testEngine.Desc("score").Limit(3).After(p.Prev).FindPage(ctx, &prev)
+	assert.NoError(t, err)
+	assert.EqualValues(t, "gef", names(prev))
+	assert.Empty(t, p.Prev)
+
+	// the conditions are kept
+	var filtered []*PageUser
+	p, err = testEngine.Where("score < ?", 3).Asc("name").Limit(4).FindPage(ctx, &filtered)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 4, len(filtered))
+	filtered = nil
+	_, err = testEngine.Where("score < ?", 3).Asc("name").Limit(4).After(p.Next).FindPage(ctx, &filtered)
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 2, len(filtered)) {
+		assert.EqualValues(t, "e", filtered[0].Name)
+		assert.EqualValues(t, "f", filtered[1].Name)
+	}
+
+	_, err = testEngine.Limit(3).After("invalid").FindPage(ctx, &page1)
+	assert.EqualValues(t, ErrInvalidCursor, err)
+}
+
+type PageNullable struct {
+	Id       int64
+	Priority *int
+}
+
+func TestFindPageNullable(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(PageNullable))
+
+	ctx := context.Background()
+	one, two := 1, 2
+	for _, priority := range []*int{nil, &one, nil, &two, &one} {
+		_, err := testEngine.Insert(ctx, &PageNullable{Priority: priority})
+		assert.NoError(t, err)
+	}
+
+	// the rows of NULL priorities are neither skipped nor repeated in any order
+	for _, desc := range []bool{false, true} {
+		var seen = make(map[int64]bool)
+		var cursor string
+		for i := 0; i < 5; i++ {
+			session := testEngine.Limit(2).After(cursor)
+			if desc {
+				session = session.Desc("priority")
+			} else {
+				session = session.Asc("priority")
+			}
+
+			var rows []PageNullable
+			page, err := session.FindPage(ctx, &rows)
+			assert.NoError(t, err)
+			for _, row := range rows {
+				assert.False(t, seen[row.Id])
+				seen[row.Id] = true
+			}
+			if cursor = page.Next; cursor == "" {
+				break
+			}
+		}
+		assert.EqualValues(t, 5, len(seen))
+	}
+}
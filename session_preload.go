@@ -0,0 +1,361 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/lingochamp/core"
+)
+
+type relationType int
+
+const (
+	hasManyRelation relationType = iota
+	many2manyRelation
+)
+
+// relation describes a has_many or many2many field of a struct.
+//
+// has_many(fk=user_id) means the column user_id of the related table refers
+// to the primary key of the struct. many2many(user_role, fk=user_id,
+// ref=role_id) means the join table user_role has the column user_id which
+// refers to the primary key of the struct and the column role_id which refers
+// to the primary key of the related table.
+type relation struct {
+	typ       relationType
+	fieldName string
+	elemType  reflect.Type
+	fk        string
+	ref       string
+	joinTable string
+}
+
+// relationOf parses the relation tag of the field of the struct type
+func (engine *Engine) relationOf(t reflect.Type, fieldName string) (*relation, error) {
+	field, ok := t.FieldByName(fieldName)
+	if !ok {
+		return nil, fmt.Errorf("%v has no field %s", t, fieldName)
+	}
+
+	var rel *relation
+	for _, key := range splitTag(field.Tag.Get(engine.TagIdentifier)) {
+		var tagName = strings.ToUpper(key)
+		var params []string
+		if pStart := strings.Index(key, "("); pStart > 0 && strings.HasSuffix(key, ")") {
+			tagName = strings.ToUpper(key[:pStart])
+			params = strings.Split(key[pStart+1:len(key)-1], ",")
+		}
+
+		switch tagName {
+		case "HAS_MANY":
+			rel = &relation{typ: hasManyRelation}
+		case "MANY2MANY":
+			rel = &relation{typ: many2manyRelation}
+		default:
+			continue
+		}
+
+		for i, param := range params {
+			param = strings.TrimSpace(param)
+			if kv := strings.SplitN(param, "=", 2); len(kv) == 2 {
+				switch strings.ToLower(strings.TrimSpace(kv[0])) {
+				case "fk":
+					rel.fk = strings.TrimSpace(kv[1])
+				case "ref":
+					rel.ref = strings.TrimSpace(kv[1])
+				default:
+					return nil, fmt.Errorf("unknown relation parameter %s of %v.%s", param, t, fieldName)
+				}
+			} else if i == 0 && rel.typ == many2manyRelation {
+				rel.joinTable = param
+			} else {
+				return nil, fmt.Errorf("unknown relation parameter %s of %v.%s", param, t, fieldName)
+			}
+		}
+		break
+	}
+	if rel == nil {
+		return nil, fmt.Errorf("%v.%s is not a has_many or many2many relation", t, fieldName)
+	}
+
+	rel.fieldName = fieldName
+	rel.elemType = field.Type
+	if rel.elemType.Kind() == reflect.Slice {
+		rel.elemType = rel.elemType.Elem()
+		if rel.elemType.Kind() == reflect.Ptr {
+			rel.elemType = rel.elemType.Elem()
+		}
+	}
+	if field.Type.Kind() != reflect.Slice || rel.elemType.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("relation %v.%s should be a slice of structs", t, fieldName)
+	}
+
+	if rel.fk == "" {
+		rel.fk = engine.ColumnMapper.Obj2Table(t.Name()) + "_id"
+	}
+	if rel.typ == many2manyRelation {
+		if rel.joinTable == "" {
+			return nil, fmt.Errorf("many2many relation %v.%s needs a join table", t, fieldName)
+		}
+		if rel.ref == "" {
+			rel.ref = engine.ColumnMapper.Obj2Table(rel.elemType.Name()) + "_id"
+		}
+	}
+	return rel, nil
+}
+
+// preloadTree is the relations to be preloaded, the key is the field name of
+// the relation and the value is the relations of the related beans
+type preloadTree map[string]preloadTree
+
+func newPreloadTree(paths []string) preloadTree {
+	var tree = make(preloadTree)
+	for _, path := range paths {
+		var node = tree
+		for _, name := range strings.Split(path, ".") {
+			name = strings.TrimSpace(name)
+			if _, ok := node[name]; !ok {
+				node[name] = make(preloadTree)
+			}
+			node = node[name]
+		}
+	}
+	return tree
+}
+
+// Preload loads the has_many and many2many relations of the beans found by
+// Find or Get. Nested relations are separated by dots, e.g.
+//
+//	engine.Preload("Orders", "Orders.Items").Find(ctx, &users)
+//
+// Every relation of every level is loaded by one query with IN conditions.
+func (session *Session) Preload(relations ...string) *Session {
+	session.statement.Preload(relations...)
+	return session
+}
+
+// relationKey returns a comparable key of the column value, the values of
+// the same record may be different types between the tables and the driver
+func relationKey(v interface{}) string {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return fmt.Sprint(v)
+}
+
+func singlePKColumn(table *core.Table) (*core.Column, error) {
+	cols := table.PKColumns()
+	if len(cols) != 1 {
+		return nil, fmt.Errorf("relation of table %s needs exactly one primary key", table.Name)
+	}
+	return cols[0], nil
+}
+
+// preload loads the relations of the tree into the beans, the beans should
+// be addressable struct values of the same type
+func (session *Session) preload(ctx context.Context, beans []reflect.Value, tree preloadTree) error {
+	if len(beans) == 0 || len(tree) == 0 {
+		return nil
+	}
+
+	var beanType = beans[0].Type()
+	table, err := session.engine.autoMapType(beans[0])
+	if err != nil {
+		return err
+	}
+	pkCol, err := singlePKColumn(table)
+	if err != nil {
+		return err
+	}
+
+	var keys = make([]interface{}, 0, len(beans))
+	var beanKeys = make([]string, len(beans))
+	var seen = make(map[string]bool, len(beans))
+	for i := range beans {
+		fieldValue, err := pkCol.ValueOfV(&beans[i])
+		if err != nil {
+			return err
+		}
+		key := fieldValue.Interface()
+		beanKeys[i] = relationKey(key)
+		if !seen[beanKeys[i]] {
+			seen[beanKeys[i]] = true
+			keys = append(keys, key)
+		}
+	}
+
+	var names = make([]string, 0, len(tree))
+	for name := range tree {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		rel, err := session.engine.relationOf(beanType, name)
+		if err != nil {
+			return err
+		}
+
+		var related map[string][]reflect.Value
+		switch rel.typ {
+		case hasManyRelation:
+			related, err = session.preloadHasMany(ctx, rel, keys, tree[name])
+		case many2manyRelation:
+			related, err = session.preloadMany2Many(ctx, rel, keys, tree[name])
+		}
+		if err != nil {
+			return err
+		}
+
+		for i := range beans {
+			field := beans[i].FieldByName(rel.fieldName)
+			values := related[beanKeys[i]]
+			slice := reflect.MakeSlice(field.Type(), 0, len(values))
+			for _, v := range values {
+				if field.Type().Elem().Kind() == reflect.Ptr {
+					slice = reflect.Append(slice, v)
+				} else {
+					slice = reflect.Append(slice, v.Elem())
+				}
+			}
+			field.Set(slice)
+		}
+	}
+	return nil
+}
+
+// findRelated finds the related beans whose column is in the keys, and
+// preloads their relations of the tree
+func (session *Session) findRelated(ctx context.Context, elemType reflect.Type, colName string, keys []interface{}, tree preloadTree) ([]reflect.Value, error) {
+	slicePtr := reflect.New(reflect.SliceOf(reflect.PtrTo(elemType)))
+	if err := session.In(colName, keys).find(ctx, slicePtr.Interface()); err != nil {
+		return nil, err
+	}
+
+	var sliceValue = slicePtr.Elem()
+	var ptrs = make([]reflect.Value, sliceValue.Len())
+	var elems = make([]reflect.Value, sliceValue.Len())
+	for i := range ptrs {
+		ptrs[i] = sliceValue.Index(i)
+		elems[i] = ptrs[i].Elem()
+	}
+	if err := session.preload(ctx, elems, tree); err != nil {
+		return nil, err
+	}
+	return ptrs, nil
+}
+
+func (session *Session) preloadHasMany(ctx context.Context, rel *relation, keys []interface{}, tree preloadTree) (map[string][]reflect.Value, error) {
+	table, err := session.engine.autoMapType(reflect.New(rel.elemType).Elem())
+	if err != nil {
+		return nil, err
+	}
+	fkCol := table.GetColumn(rel.fk)
+	if fkCol == nil {
+		return nil, fmt.Errorf("table %s has no column %s", table.Name, rel.fk)
+	}
+
+	ptrs, err := session.findRelated(ctx, rel.elemType, rel.fk, keys, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var related = make(map[string][]reflect.Value)
+	for _, ptr := range ptrs {
+		elem := ptr.Elem()
+		fieldValue, err := fkCol.ValueOfV(&elem)
+		if err != nil {
+			return nil, err
+		}
+		key := relationKey(fieldValue.Interface())
+		related[key] = append(related[key], ptr)
+	}
+	return related, nil
+}
+
+func (session *Session) preloadMany2Many(ctx context.Context, rel *relation, keys []interface{}, tree preloadTree) (map[string][]reflect.Value, error) {
+	table, err := session.engine.autoMapType(reflect.New(rel.elemType).Elem())
+	if err != nil {
+		return nil, err
+	}
+	pkCol, err := singlePKColumn(table)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlStr, args, err := session.Table(rel.joinTable).Cols(rel.fk, rel.ref).In(rel.fk, keys).genQuerySQL()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := session.queryRows(ctx, sqlStr, args...)
+	if err != nil {
+		return nil, err
+	}
+	joins, err := rows2Interfaces(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(joins) == 0 {
+		return nil, nil
+	}
+
+	var refs = make([]interface{}, 0, len(joins))
+	var seen = make(map[string]bool, len(joins))
+	for _, join := range joins {
+		if key := relationKey(join[rel.ref]); !seen[key] {
+			seen[key] = true
+			refs = append(refs, join[rel.ref])
+		}
+	}
+
+	ptrs, err := session.findRelated(ctx, rel.elemType, pkCol.Name, refs, tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var byPK = make(map[string]reflect.Value, len(ptrs))
+	for _, ptr := range ptrs {
+		elem := ptr.Elem()
+		fieldValue, err := pkCol.ValueOfV(&elem)
+		if err != nil {
+			return nil, err
+		}
+		byPK[relationKey(fieldValue.Interface())] = ptr
+	}
+
+	var related = make(map[string][]reflect.Value)
+	for _, join := range joins {
+		if ptr, ok := byPK[relationKey(join[rel.ref])]; ok {
+			key := relationKey(join[rel.fk])
+			related[key] = append(related[key], ptr)
+		}
+	}
+	return related, nil
+}
+
+// preloadSlice preloads the relations of the beans in the slice
+func (session *Session) preloadSlice(ctx context.Context, rowsSlicePtr interface{}, paths []string) error {
+	sliceValue := reflect.Indirect(reflect.ValueOf(rowsSlicePtr))
+	if sliceValue.Kind() != reflect.Slice {
+		return errors.New("Preload needs a pointer to a slice")
+	}
+
+	var beans = make([]reflect.Value, 0, sliceValue.Len())
+	for i := 0; i < sliceValue.Len(); i++ {
+		bean := reflect.Indirect(sliceValue.Index(i))
+		if bean.Kind() != reflect.Struct {
+			return errors.New("Preload needs a slice of structs")
+		}
+		beans = append(beans, bean)
+	}
+	return session.preload(ctx, beans, newPreloadTree(paths))
+}
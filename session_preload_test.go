@@ -0,0 +1,104 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type PreloadUser struct {
+	Id     int64
+	Name   string
+	Orders []*PreloadOrder `xorm:"has_many(fk=user_id)"`
+	Roles  []PreloadRole   `xorm:"many2many(preload_user_role,fk=user_id,ref=role_id)"`
+}
+
+type PreloadOrder struct {
+	Id     int64
+	UserId int64
+	Items  []PreloadItem `xorm:"has_many(fk=order_id)"`
+}
+
+type PreloadItem struct {
+	Id      int64
+	OrderId int64
+	Name    string
+}
+
+type PreloadRole struct {
+	Id   int64
+	Name string
+}
+
+type PreloadUserRole struct {
+	UserId int64 `xorm:"pk"`
+	RoleId int64 `xorm:"pk"`
+}
+
+func TestPreload(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(PreloadUser), new(PreloadOrder), new(PreloadItem),
+		new(PreloadRole), new(PreloadUserRole))
+
+	ctx := context.Background()
+	users := []PreloadUser{{Name: "u1"}, {Name: "u2"}, {Name: "u3"}}
+	for i := range users {
+		_, err := testEngine.Insert(ctx, &users[i])
+		assert.NoError(t, err)
+	}
+
+	orders := []PreloadOrder{{UserId: users[0].Id}, {UserId: users[0].Id}, {UserId: users[1].Id}}
+	for i := range orders {
+		_, err := testEngine.Insert(ctx, &orders[i])
+		assert.NoError(t, err)
+	}
+
+	items := []PreloadItem{{OrderId: orders[0].Id, Name: "a"}, {OrderId: orders[0].Id, Name: "b"}, {OrderId: orders[2].Id, Name: "c"}}
+	for i := range items {
+		_, err := testEngine.Insert(ctx, &items[i])
+		assert.NoError(t, err)
+	}
+
+	roles := []PreloadRole{{Name: "admin"}, {Name: "guest"}}
+	for i := range roles {
+		_, err := testEngine.Insert(ctx, &roles[i])
+		assert.NoError(t, err)
+	}
+	_, err := testEngine.Insert(ctx, []PreloadUserRole{
+		{UserId: users[0].Id, RoleId: roles[0].Id},
+		{UserId: users[0].Id, RoleId: roles[1].Id},
+		{UserId: users[1].Id, RoleId: roles[1].Id},
+	})
+	assert.NoError(t, err)
+
+	var found []PreloadUser
+	err = testEngine.Preload("Orders.Items", "Roles").Asc("id").Find(ctx, &found)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 3, len(found))
+
+	assert.EqualValues(t, 2, len(found[0].Orders))
+	assert.EqualValues(t, 2, len(found[0].Orders[0].Items)+len(found[0].Orders[1].Items))
+	assert.EqualValues(t, 2, len(found[0].Roles))
+	assert.EqualValues(t, 1, len(found[1].Orders))
+	assert.EqualValues(t, 1, len(found[1].Orders[0].Items))
+	assert.EqualValues(t, "c", found[1].Orders[0].Items[0].Name)
+	assert.EqualValues(t, 1, len(found[1].Roles))
+	assert.EqualValues(t, "guest", found[1].Roles[0].Name)
+	assert.EqualValues(t, 0, len(found[2].Orders))
+	assert.EqualValues(t, 0, len(found[2].Roles))
+
+	var user PreloadUser
+	has, err := testEngine.ID(users[1].Id).Preload("Orders").Get(ctx, &user)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 1, len(user.Orders))
+	assert.Nil(t, user.Orders[0].Items)
+
+	err = testEngine.Preload("Name").Find(ctx, &found)
+	assert.Error(t, err)
+}
@@ -60,7 +60,7 @@ func (session *Session) genQuerySQL(sqlorArgs ...interface{}) (string, []interfa
 		return "", nil, err
 	}
 
-	args := append(session.statement.joinArgs, condArgs...)
+	args := session.statement.selectArgs(condArgs)
 	sqlStr, err := session.statement.genSelectSQL(columnStr, condSQL)
 	if err != nil {
 		return "", nil, err
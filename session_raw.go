@@ -22,8 +22,24 @@ func (session *Session) queryPreprocess(sqlStr *string, paramStr ...interface{})
 	session.lastSQLArgs = paramStr
 }
 
-func (session *Session) queryRows(ctx context.Context, sqlStr string, args ...interface{}) (*core.Rows, error) {
+// checkBindParams fails the statement whose parameters exceed the max bind
+// parameters of the dialect before it's sent to the database
+func (session *Session) checkBindParams(args []interface{}) error {
+	if limit := session.engine.maxBindParams(); limit > 0 && len(args) > limit {
+		return ErrTooManyParams
+	}
+	return nil
+}
+
+func (session *Session) queryRows(ctx context.Context, sqlStr string, args ...interface{}) (_ *core.Rows, err error) {
 	defer session.resetStatement()
+	defer func() {
+		err = session.engine.classifyError(err)
+	}()
+	if err := session.checkBindParams(args); err != nil {
+		return nil, err
+	}
+	ctx = session.txContext(ctx)
 
 	session.queryPreprocess(&sqlStr, args...)
 
@@ -149,8 +165,15 @@ func (session *Session) queryBytes(ctx context.Context, sqlStr string, args ...i
 	return rows2maps(rows)
 }
 
-func (session *Session) exec(ctx context.Context, sqlStr string, args ...interface{}) (sql.Result, error) {
+func (session *Session) exec(ctx context.Context, sqlStr string, args ...interface{}) (_ sql.Result, err error) {
 	defer session.resetStatement()
+	defer func() {
+		err = session.engine.classifyError(err)
+	}()
+	if err := session.checkBindParams(args); err != nil {
+		return nil, err
+	}
+	ctx = session.txContext(ctx)
 
 	session.queryPreprocess(&sqlStr, args...)
 
@@ -174,6 +197,10 @@ func (session *Session) exec(ctx context.Context, sqlStr string, args ...interfa
 		}
 	}
 
+	if session.dryRun != nil {
+		return session.dryRunExec(sqlStr, args...)
+	}
+
 	if !session.isAutoCommit {
 		return session.tx.ExecContext(ctx, sqlStr, args...)
 	}
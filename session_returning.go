@@ -0,0 +1,89 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"reflect"
+)
+
+// Returning fills the bean of Insert, Update and Delete with the columns of
+// the affected record, when inserting a slice every element is filled in
+// order. Use "*" to return all the columns. Postgres and SQLite use the
+// RETURNING clause and MSSQL uses the OUTPUT clause, the other dialects will
+// return ErrReturningUnsupported.
+//
+// As Update and Delete may affect many records, they return ErrReturningRows
+// and roll back if more than one record is returned into the bean.
+func (session *Session) Returning(columns ...string) *Session {
+	session.statement.Returning(columns...)
+	return session
+}
+
+// queryReturning executes the statement with the returning clause and fills
+// the beans with the returned rows in order, it returns the count of the
+// returned rows as the affected rows. The rows more than the beans are an
+// error.
+func (session *Session) queryReturning(ctx context.Context, beans []interface{}, sqlStr string, args ...interface{}) (int64, error) {
+	// the statement will be reset after querying
+	table := session.statement.RefTable
+
+	rows, err := session.queryRows(ctx, sqlStr, args...)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	fields, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int
+	for rows.Next() {
+		if affected < len(beans) {
+			bean := beans[affected]
+			scanResults, err := session.row2Slice(rows, fields, bean)
+			if err != nil {
+				return int64(affected), err
+			}
+
+			dataStruct := rValue(bean)
+			if _, err := session.slice2Bean(ctx, scanResults, fields, bean, &dataStruct, table); err != nil {
+				return int64(affected), err
+			}
+		}
+		affected++
+	}
+	if err := rows.Err(); err != nil {
+		return int64(affected), err
+	}
+	if affected > len(beans) {
+		return int64(affected), ErrReturningRows
+	}
+	return int64(affected), nil
+}
+
+// queryReturningBean executes the update or the delete with the returning
+// clause in a transaction, which is rolled back if more than one row is
+// returned into the bean.
+func (session *Session) queryReturningBean(ctx context.Context, bean interface{}, sqlStr string, args ...interface{}) (affected int64, err error) {
+	endTx, err := session.beginImplicitTx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer endTx(&err)
+
+	return session.queryReturning(ctx, []interface{}{bean}, sqlStr, args...)
+}
+
+// sliceElemPtrs returns the pointers to the elements of the slice
+func sliceElemPtrs(sliceValue reflect.Value) []interface{} {
+	var beans = make([]interface{}, sliceValue.Len())
+	for i := range beans {
+		beans[i] = reflect.Indirect(sliceValue.Index(i)).Addr().Interface()
+	}
+	return beans
+}
@@ -0,0 +1,80 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lingochamp/core"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReturning(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type ReturningStruct struct {
+		Id     int64
+		Name   string
+		Status int `xorm:"default 5"`
+	}
+
+	assertSync(t, new(ReturningStruct))
+
+	ctx := context.Background()
+	switch testEngine.Dialect().DBType() {
+	case core.POSTGRES, core.SQLITE, core.MSSQL:
+	default:
+		_, err := testEngine.Returning("status").Omit("status").Insert(ctx, &ReturningStruct{Name: "a"})
+		assert.True(t, errors.Is(err, ErrReturningUnsupported))
+		return
+	}
+
+	var s = ReturningStruct{Name: "a"}
+	cnt, err := testEngine.Returning("status").Omit("status").Insert(ctx, &s)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.True(t, s.Id > 0)
+	assert.EqualValues(t, 5, s.Status)
+
+	var ss = []ReturningStruct{{Name: "b"}, {Name: "c"}}
+	cnt, err = testEngine.Returning("*").Omit("status").Insert(ctx, &ss)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+	assert.True(t, ss[0].Id > 0)
+	assert.True(t, ss[1].Id > ss[0].Id)
+	assert.EqualValues(t, "c", ss[1].Name)
+	assert.EqualValues(t, 5, ss[1].Status)
+
+	var updated = ReturningStruct{Status: 6}
+	cnt, err = testEngine.ID(s.Id).Returning("*").Update(ctx, &updated)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, s.Id, updated.Id)
+	assert.EqualValues(t, "a", updated.Name)
+	assert.EqualValues(t, 6, updated.Status)
+
+	var deleted ReturningStruct
+	cnt, err = testEngine.Where("name = ?", "b").Returning("id", "name").Delete(ctx, &deleted)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+	assert.EqualValues(t, ss[0].Id, deleted.Id)
+	assert.EqualValues(t, "b", deleted.Name)
+
+	// more than one row could not be returned into the bean, and the update
+	// and the delete are rolled back
+	_, err = testEngine.Where("id > ?", 0).Returning("*").Update(ctx, &ReturningStruct{Status: 7})
+	assert.True(t, errors.Is(err, ErrReturningRows))
+	cnt, err = testEngine.Count(ctx, &ReturningStruct{Status: 7})
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0, cnt)
+
+	_, err = testEngine.Where("id > ?", 0).Returning("*").Delete(ctx, new(ReturningStruct))
+	assert.True(t, errors.Is(err, ErrReturningRows))
+	cnt, err = testEngine.Count(ctx, new(ReturningStruct))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 2, cnt)
+}
@@ -7,8 +7,10 @@ package xorm
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
 	"strings"
 
@@ -242,6 +244,92 @@ func (session *Session) addUnique(ctx context.Context, tableName, uqeName string
 	return err
 }
 
+// addForeignKeys adds the foreign keys which are not in the existing foreign
+// keys of the table, the existing one with the same name but different
+// definition is dropped at first
+func (session *Session) addForeignKeys(ctx context.Context, tableName string, fks, existing []*ForeignKey) error {
+	engine := session.engine
+	for _, fk := range fks {
+		var found bool
+		for _, oriFK := range existing {
+			if fk.Equal(oriFK) {
+				found = true
+				break
+			}
+			if strings.EqualFold(fk.Name, oriFK.Name) {
+				if engine.dialect.DBType() == core.SQLITE {
+					break
+				}
+				if _, err := session.exec(ctx, engine.dropForeignKeySQL(tableName, oriFK)); err != nil {
+					return err
+				}
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		if engine.dialect.DBType() == core.SQLITE {
+			engine.logger(ctx).Warnf("Table %s foreign key %s could not be added to an existing sqlite table",
+				tableName, fk.Name)
+			continue
+		}
+		if _, err := session.exec(ctx, engine.addForeignKeySQL(tableName, fk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DryRun writes the statements to w instead of executing them, it's used to
+// review the statements of Sync2 before changing the database, e.g.
+//
+//	engine.DryRun(os.Stdout).Sync2(ctx, new(User))
+func (session *Session) DryRun(w io.Writer) *Session {
+	session.dryRun = w
+	return session
+}
+
+func (session *Session) dryRunExec(sqlStr string, args ...interface{}) (sql.Result, error) {
+	sqlStr = strings.TrimSuffix(strings.TrimSpace(sqlStr), ";")
+	var err error
+	if len(args) > 0 {
+		_, err = fmt.Fprintf(session.dryRun, "%s; -- %#v\n", sqlStr, args)
+	} else {
+		_, err = fmt.Fprintf(session.dryRun, "%s;\n", sqlStr)
+	}
+	return driver.RowsAffected(0), err
+}
+
+// DropUnused makes Sync2 drop the columns of the tables which are not declared
+// by the structs, and SchemaDiff report them. It refuses to drop the columns
+// with data and returns ErrColumnNotEmpty unless force is true.
+func (session *Session) DropUnused(force bool) *Session {
+	session.dropUnused = true
+	session.forceDrop = force
+	return session
+}
+
+// rebuildTable rebuilds the sqlite table in a transaction, the foreign keys
+// referring to the table should be disabled by the connection
+func (session *Session) rebuildTable(ctx context.Context, table, oriTable *core.Table, tableName string) (err error) {
+	if session.dryRun == nil {
+		var endTx func(*error)
+		if endTx, err = session.beginImplicitTx(ctx); err != nil {
+			return err
+		}
+		defer endTx(&err)
+	}
+
+	for _, sqlStr := range session.engine.sqliteRebuildSQLs(table, oriTable, tableName) {
+		if _, err = session.exec(ctx, sqlStr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Sync2 synchronize structs to database tables
 func (session *Session) Sync2(ctx context.Context, beans ...interface{}) error {
 	engine := session.engine
@@ -257,6 +345,9 @@ func (session *Session) Sync2(ctx context.Context, beans ...interface{}) error {
 	}
 
 	var structTables []*core.Table
+	var createdTables = make(map[string]bool)
+	// the existing tables whose columns are renamed
+	var oriTables = make(map[string]*core.Table)
 
 	for _, bean := range beans {
 		v := rValue(bean)
@@ -290,7 +381,21 @@ func (session *Session) Sync2(ctx context.Context, beans ...interface{}) error {
 			if err != nil {
 				return err
 			}
+			createdTables[tbName] = true
 		} else {
+			var renames []*SchemaChange
+			renames, oriTable = engine.renameColumns(table, oriTable, tbName)
+			for _, change := range renames {
+				engine.logger(ctx).Infof("Table %s column %s is renamed from %s",
+					tbName, change.Column.Name, change.OldColumn.Name)
+				_, err = session.exec(ctx, engine.renameColumnSQL(tbName, change.OldColumn.Name, change.Column))
+				if err != nil {
+					return err
+				}
+			}
+			oriTables[tbName] = oriTable
+
+			var needRebuild bool
 			for _, col := range table.Columns() {
 				var oriCol *core.Column
 				for _, col2 := range oriTable.Columns() {
@@ -301,52 +406,22 @@ func (session *Session) Sync2(ctx context.Context, beans ...interface{}) error {
 				}
 
 				if oriCol != nil {
-					expectedType := engine.dialect.SqlType(col)
-					curType := engine.dialect.SqlType(oriCol)
-					if expectedType != curType {
-						if expectedType == core.Text &&
-							strings.HasPrefix(curType, core.Varchar) {
-							// currently only support mysql & postgres
-							if engine.dialect.DBType() == core.MYSQL ||
-								engine.dialect.DBType() == core.POSTGRES {
-								engine.logger(ctx).Infof("Table %s column %s change type from %s to %s\n",
-									tbName, col.Name, curType, expectedType)
-								_, err = session.exec(ctx, engine.dialect.ModifyColumnSql(table.Name, col))
-							} else {
-								engine.logger(ctx).Warnf("Table %s column %s db type is %s, struct type is %s\n",
-									tbName, col.Name, curType, expectedType)
-							}
-						} else if strings.HasPrefix(curType, core.Varchar) && strings.HasPrefix(expectedType, core.Varchar) {
-							if engine.dialect.DBType() == core.MYSQL {
-								if oriCol.Length < col.Length {
-									engine.logger(ctx).Infof("Table %s column %s change type from varchar(%d) to varchar(%d)\n",
-										tbName, col.Name, oriCol.Length, col.Length)
-									_, err = session.exec(ctx, engine.dialect.ModifyColumnSql(table.Name, col))
-								}
-							}
+					if details := engine.columnChanges(table, col, oriCol); len(details) > 0 {
+						if engine.GeneratedColumn(table, col.Name) != nil {
+							engine.logger(ctx).Warnf("Table %s generated column %s could not be altered: %s",
+								tbName, col.Name, strings.Join(details, ", "))
+						} else if engine.dialect.DBType() == core.SQLITE {
+							needRebuild = true
 						} else {
-							if !(strings.HasPrefix(curType, expectedType) && curType[len(expectedType)] == '(') {
-								engine.logger(ctx).Warnf("Table %s column %s db type is %s, struct type is %s",
-									tbName, col.Name, curType, expectedType)
-							}
-						}
-					} else if expectedType == core.Varchar {
-						if engine.dialect.DBType() == core.MYSQL {
-							if oriCol.Length < col.Length {
-								engine.logger(ctx).Infof("Table %s column %s change type from varchar(%d) to varchar(%d)\n",
-									tbName, col.Name, oriCol.Length, col.Length)
-								_, err = session.exec(ctx, engine.dialect.ModifyColumnSql(table.Name, col))
+							engine.logger(ctx).Infof("Table %s column %s changes %s",
+								tbName, col.Name, strings.Join(details, ", "))
+							for _, sqlStr := range engine.alterColumnSQLs(tbName, col, oriCol) {
+								if _, err = session.exec(ctx, sqlStr); err != nil {
+									break
+								}
 							}
 						}
 					}
-					if col.Default != oriCol.Default {
-						engine.logger(ctx).Warnf("Table %s Column %s db default is %s, struct default is %s",
-							tbName, col.Name, oriCol.Default, col.Default)
-					}
-					if col.Nullable != oriCol.Nullable {
-						engine.logger(ctx).Warnf("Table %s Column %s db nullable is %v, struct nullable is %v",
-							tbName, col.Name, oriCol.Nullable, col.Nullable)
-					}
 				} else {
 					session.statement.RefTable = table
 					session.statement.tableName = tbName
@@ -357,6 +432,13 @@ func (session *Session) Sync2(ctx context.Context, beans ...interface{}) error {
 				}
 			}
 
+			if needRebuild {
+				engine.logger(ctx).Infof("Table %s is rebuilt to alter columns", tbName)
+				if err = session.rebuildTable(ctx, table, oriTable, tbName); err != nil {
+					return err
+				}
+			}
+
 			var foundIndexNames = make(map[string]bool)
 			var addedNames = make(map[string]*core.Index)
 
@@ -386,7 +468,16 @@ func (session *Session) Sync2(ctx context.Context, beans ...interface{}) error {
 				}
 			}
 
+			// mysql creates the indexes of the foreign keys with the same names
+			var fkIndexNames = make(map[string]bool)
+			for _, fk := range engine.ForeignKeys(oriTable) {
+				fkIndexNames[strings.ToLower(fk.Name)] = true
+			}
+
 			for name2, index2 := range oriTable.Indexes {
+				if fkIndexNames[strings.ToLower(name2)] {
+					continue
+				}
 				if _, ok := foundIndexNames[name2]; !ok {
 					sql := engine.dialect.DropIndexSql(tbName, index2)
 					_, err = session.exec(ctx, sql)
@@ -413,24 +504,80 @@ func (session *Session) Sync2(ctx context.Context, beans ...interface{}) error {
 		}
 	}
 
-	for _, table := range tables {
-		var oriTable *core.Table
-		for _, structTable := range structTables {
-			if strings.EqualFold(table.Name, session.tbNameNoSchema(structTable)) {
-				oriTable = structTable
-				break
+	// the foreign keys are added after all the tables are created, so the
+	// tables could refer to each other
+	for _, table := range structTables {
+		var tbName = session.tbNameNoSchema(table)
+		var existing []*ForeignKey
+		if createdTables[tbName] {
+			if engine.dialect.DBType() == core.SQLITE {
+				continue
+			}
+		} else {
+			for _, tb := range tables {
+				if strings.EqualFold(tb.Name, tbName) {
+					existing = engine.ForeignKeys(tb)
+					break
+				}
 			}
 		}
 
-		if oriTable == nil {
-			//engine.LogWarnf("Table %s has no struct to mapping it", table.Name)
+		err = session.addForeignKeys(ctx, tbName, engine.tableForeignKeys(table, tbName), existing)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, table := range structTables {
+		var tbName = session.tbNameNoSchema(table)
+		oriTable, ok := oriTables[tbName]
+		if !ok {
 			continue
 		}
 
-		for _, colName := range table.ColumnsSeq() {
-			if oriTable.GetColumn(colName) == nil {
-				engine.logger(ctx).Warnf("Table %s has column %s but struct has not related field", table.Name, colName)
+		for _, colName := range oriTable.ColumnsSeq() {
+			if table.GetColumn(colName) != nil {
+				continue
+			}
+			if !session.dropUnused {
+				engine.logger(ctx).Warnf("Table %s has column %s but struct has not related field", tbName, colName)
+				continue
 			}
+			if err = session.dropUnusedColumn(ctx, tbName, colName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// dropUnusedColumn drops the column which is not declared by the struct, the
+// column with data is not dropped unless it's forced
+func (session *Session) dropUnusedColumn(ctx context.Context, tableName, colName string) error {
+	engine := session.engine
+	if !session.forceDrop {
+		sqlStr := fmt.Sprintf("SELECT COUNT(*) FROM %v WHERE %v IS NOT NULL", engine.Quote(tableName), engine.Quote(colName))
+		rows, err := session.queryRows(ctx, sqlStr)
+		if err != nil {
+			return err
+		}
+		var count int64
+		if rows.Next() {
+			err = rows.Scan(&count)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s.%s has %d values", ErrColumnNotEmpty, tableName, colName, count)
+		}
+	}
+
+	engine.logger(ctx).Infof("Table %s column %s is dropped", tableName, colName)
+	for _, sqlStr := range engine.dropColumnSQLs(tableName, colName) {
+		if _, err := session.exec(ctx, sqlStr); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -0,0 +1,542 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lingochamp/core"
+)
+
+// SchemaChangeType is the type of a SchemaChange
+type SchemaChangeType int
+
+// enumerates all the schema change types
+const (
+	SchemaAddTable SchemaChangeType = iota + 1
+	SchemaAddColumn
+	SchemaDropColumn
+	SchemaAlterColumn
+	SchemaAddIndex
+	SchemaDropIndex
+	SchemaAddForeignKey
+	SchemaDropForeignKey
+	SchemaRenameColumn
+)
+
+var schemaChangeTypeNames = map[SchemaChangeType]string{
+	SchemaAddTable:       "add table",
+	SchemaAddColumn:      "add column",
+	SchemaDropColumn:     "drop column",
+	SchemaAlterColumn:    "alter column",
+	SchemaAddIndex:       "add index",
+	SchemaDropIndex:      "drop index",
+	SchemaAddForeignKey:  "add foreign key",
+	SchemaDropForeignKey: "drop foreign key",
+	SchemaRenameColumn:   "rename column",
+}
+
+func (tp SchemaChangeType) String() string {
+	if name, ok := schemaChangeTypeNames[tp]; ok {
+		return name
+	}
+	return fmt.Sprintf("SchemaChangeType(%d)", int(tp))
+}
+
+// SchemaChange is a difference between a struct and its table in the
+// database. Column is the column of the struct, or the column of the database
+// when it's dropped, and OldColumn is the column of the database when it's
+// altered or renamed. Details describes what is altered, e.g. "default 0 -> 1".
+type SchemaChange struct {
+	Type       SchemaChangeType
+	TableName  string
+	Table      *core.Table
+	Column     *core.Column
+	OldColumn  *core.Column
+	Index      *core.Index
+	ForeignKey *ForeignKey
+	Details    []string
+
+	oriTable *core.Table
+}
+
+func (change *SchemaChange) String() string {
+	var name string
+	switch {
+	case change.Column != nil:
+		name = change.Column.Name
+	case change.Index != nil:
+		name = change.Index.Name
+	case change.ForeignKey != nil:
+		name = change.ForeignKey.Name
+	}
+
+	s := fmt.Sprintf("%v %v", change.Type, change.TableName)
+	if name != "" {
+		s += "." + name
+	}
+	if len(change.Details) > 0 {
+		s += ": " + strings.Join(change.Details, ", ")
+	}
+	return s
+}
+
+// SchemaDiff is the differences between the structs and the database
+type SchemaDiff struct {
+	Changes []*SchemaChange
+
+	engine *Engine
+}
+
+// IsEmpty returns true if the database is the same as the structs
+func (diff *SchemaDiff) IsEmpty() bool {
+	return len(diff.Changes) == 0
+}
+
+// SQLs returns the DDL statements of the dialect to apply the changes, the
+// sqlite tables with altered columns are rebuilt
+func (diff *SchemaDiff) SQLs() ([]string, error) {
+	var sqls []string
+	var rebuilt = make(map[string]bool)
+	for _, change := range diff.Changes {
+		if change.Type == SchemaAlterColumn && diff.engine.dialect.DBType() == core.SQLITE {
+			if !rebuilt[change.TableName] {
+				rebuilt[change.TableName] = true
+				sqls = append(sqls, diff.engine.sqliteRebuildSQLs(change.Table, change.oriTable, change.TableName)...)
+			}
+			continue
+		}
+
+		changeSQLs, err := diff.engine.schemaChangeSQLs(change)
+		if err != nil {
+			return nil, err
+		}
+		sqls = append(sqls, changeSQLs...)
+	}
+	return sqls, nil
+}
+
+// tableStatement returns a statement of the table to generate the DDL
+func (engine *Engine) tableStatement(table *core.Table, tableName string) *Statement {
+	var statement Statement
+	statement.Init()
+	statement.Engine = engine
+	statement.RefTable = table
+	statement.AltTableName = tableName
+	return &statement
+}
+
+func sortedIndexNames(indexes map[string]*core.Index) []string {
+	var names = make([]string, 0, len(indexes))
+	for name := range indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (engine *Engine) schemaChangeSQLs(change *SchemaChange) ([]string, error) {
+	dialect := engine.dialect
+	tableName := change.TableName
+	var isSqlite = dialect.DBType() == core.SQLITE
+
+	switch change.Type {
+	case SchemaAddTable:
+		statement := engine.tableStatement(change.Table, tableName)
+		sqls := []string{statement.genCreateTableSQL()}
+		for _, name := range sortedIndexNames(change.Table.Indexes) {
+			sqls = append(sqls, dialect.CreateIndexSql(tableName, change.Table.Indexes[name]))
+		}
+		return sqls, nil
+	case SchemaAddColumn:
+		sqlStr, _ := engine.tableStatement(change.Table, tableName).genAddColumnStr(change.Column)
+		return []string{sqlStr}, nil
+	case SchemaDropColumn:
+		return engine.dropColumnSQLs(tableName, change.Column.Name), nil
+	case SchemaAlterColumn:
+		return engine.alterColumnSQLs(tableName, change.Column, change.OldColumn), nil
+	case SchemaRenameColumn:
+		return []string{engine.renameColumnSQL(tableName, change.OldColumn.Name, change.Column)}, nil
+	case SchemaAddIndex:
+		return []string{dialect.CreateIndexSql(tableName, change.Index)}, nil
+	case SchemaDropIndex:
+		return []string{dialect.DropIndexSql(tableName, change.Index)}, nil
+	case SchemaAddForeignKey:
+		if isSqlite {
+			return nil, fmt.Errorf("%v: sqlite could not add constraints to an existing table", change)
+		}
+		return []string{engine.addForeignKeySQL(tableName, change.ForeignKey)}, nil
+	case SchemaDropForeignKey:
+		if isSqlite {
+			return nil, fmt.Errorf("%v: sqlite could not drop constraints of an existing table", change)
+		}
+		return []string{engine.dropForeignKeySQL(tableName, change.ForeignKey)}, nil
+	}
+	return nil, fmt.Errorf("unknown schema change %v", change)
+}
+
+// SchemaDiff compares the structs with their tables in the database and
+// returns the changes to make the database the same as the structs. The
+// database is not changed, use SQLs of the diff to get the DDL statements.
+// The unused columns are dropped only if DropUnused is set, the same as Sync2.
+func (session *Session) SchemaDiff(ctx context.Context, beans ...interface{}) (*SchemaDiff, error) {
+	engine := session.engine
+
+	if session.isAutoClose {
+		session.isAutoClose = false
+		defer session.Close()
+	}
+
+	tables, err := engine.DBMetas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var diff = &SchemaDiff{engine: engine}
+	var dropForeignKeys, addForeignKeys []*SchemaChange
+	for _, bean := range beans {
+		table, err := engine.mapType(rValue(bean))
+		if err != nil {
+			return nil, err
+		}
+		tbName := session.tbNameNoSchema(table)
+
+		var oriTable *core.Table
+		for _, tb := range tables {
+			if strings.EqualFold(tb.Name, tbName) {
+				oriTable = tb
+				break
+			}
+		}
+
+		if oriTable == nil {
+			diff.Changes = append(diff.Changes, &SchemaChange{
+				Type:      SchemaAddTable,
+				TableName: tbName,
+				Table:     table,
+			})
+			if engine.dialect.DBType() == core.SQLITE {
+				// the foreign keys are declared by the CREATE TABLE statement
+				continue
+			}
+			for _, fk := range engine.tableForeignKeys(table, tbName) {
+				addForeignKeys = append(addForeignKeys, &SchemaChange{
+					Type:       SchemaAddForeignKey,
+					TableName:  tbName,
+					Table:      table,
+					ForeignKey: fk,
+				})
+			}
+			continue
+		}
+
+		renames, oriTable := engine.renameColumns(table, oriTable, tbName)
+		diff.Changes = append(diff.Changes, renames...)
+		diff.Changes = append(diff.Changes, engine.diffColumns(table, oriTable, tbName, session.dropUnused)...)
+		diff.Changes = append(diff.Changes, engine.diffIndexes(table, oriTable, tbName)...)
+
+		drops, adds := engine.diffForeignKeys(table, oriTable, tbName)
+		dropForeignKeys = append(dropForeignKeys, drops...)
+		addForeignKeys = append(addForeignKeys, adds...)
+	}
+
+	// the foreign keys are dropped before the columns they refer to, and
+	// added after the tables they refer to
+	diff.Changes = append(dropForeignKeys, diff.Changes...)
+	diff.Changes = append(diff.Changes, addForeignKeys...)
+	return diff, nil
+}
+
+// columnTypeChanged returns true if the types are different, the type in
+// the database may have a length while the struct's one has the default
+func columnTypeChanged(dialect core.Dialect, col, oriCol *core.Column) bool {
+	expectedType := dialect.SqlType(col)
+	curType := dialect.SqlType(oriCol)
+	if expectedType != curType {
+		return !(strings.HasPrefix(curType, expectedType) && curType[len(expectedType)] == '(')
+	}
+	if expectedType == core.Varchar {
+		return col.Length != oriCol.Length
+	}
+	return false
+}
+
+// alterColumnSQLs returns the statements to change the column from oriCol to
+// col, the dialects which couldn't alter all of them modify the type only
+func (engine *Engine) alterColumnSQLs(tableName string, col, oriCol *core.Column) []string {
+	if dialect, ok := engine.dialect.(alterColumnDialect); ok {
+		return dialect.AlterColumnSqls(tableName, col, oriCol)
+	}
+	return []string{engine.dialect.ModifyColumnSql(tableName, col)}
+}
+
+// sqliteRebuildSQLs returns the statements to rebuild the table by the
+// struct since sqlite could not alter columns. A new table is created, the
+// data is copied, then the old table is dropped and the new one is renamed.
+// The columns of the database which are not in the struct are kept.
+func (engine *Engine) sqliteRebuildSQLs(table, oriTable *core.Table, tableName string) []string {
+	quote := engine.Quote
+	tmpName := tableName + "__xorm_rebuild"
+
+	newTable := core.NewEmptyTable()
+	newTable.Name = tableName
+	newTable.Type = table.Type
+	var copyCols []string
+	for _, col := range table.Columns() {
+		newTable.AddColumn(col)
+		if oriTable.GetColumn(col.Name) != nil && engine.GeneratedColumn(table, col.Name) == nil {
+			copyCols = append(copyCols, quote(col.Name))
+		}
+	}
+	for _, oriCol := range oriTable.Columns() {
+		if table.GetColumn(oriCol.Name) == nil {
+			newTable.AddColumn(oriCol)
+			copyCols = append(copyCols, quote(oriCol.Name))
+		}
+	}
+
+	// the constraints are named by the table name, only the created table is
+	// the temporary one
+	createSQL := engine.tableStatement(newTable, tableName).genCreateTableSQL()
+	cols := strings.Join(copyCols, ", ")
+	sqls := []string{
+		fmt.Sprintf("DROP TABLE IF EXISTS %s", quote(tmpName)),
+		strings.Replace(createSQL, quote(tableName), quote(tmpName), 1),
+		fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quote(tmpName), cols, cols, quote(tableName)),
+		fmt.Sprintf("DROP TABLE %s", quote(tableName)),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quote(tmpName), quote(tableName)),
+	}
+	for _, name := range sortedIndexNames(oriTable.Indexes) {
+		sqls = append(sqls, engine.dialect.CreateIndexSql(tableName, oriTable.Indexes[name]))
+	}
+	return sqls
+}
+
+func columnDefaultChanged(col, oriCol *core.Column) bool {
+	return col.Default != oriCol.Default && !col.IsAutoIncrement
+}
+
+func columnNullableChanged(col, oriCol *core.Column) bool {
+	return col.Nullable != oriCol.Nullable && !col.IsPrimaryKey
+}
+
+// alterColumnDialect is implemented by the dialects which could change the
+// type, default and nullability of a column
+type alterColumnDialect interface {
+	AlterColumnSqls(tableName string, col, oriCol *core.Column) []string
+}
+
+// columnChanges describes the differences between the column of the struct
+// and the column of the database
+func (engine *Engine) columnChanges(table *core.Table, col, oriCol *core.Column) []string {
+	dialect := engine.dialect
+	var details []string
+	if columnTypeChanged(dialect, col, oriCol) {
+		details = append(details, fmt.Sprintf("type %v -> %v", dialect.SqlType(oriCol), dialect.SqlType(col)))
+	}
+	if columnDefaultChanged(col, oriCol) && engine.GeneratedColumn(table, col.Name) == nil {
+		details = append(details, fmt.Sprintf("default %v -> %v", oriCol.Default, col.Default))
+	}
+	if columnNullableChanged(col, oriCol) {
+		details = append(details, fmt.Sprintf("nullable %v -> %v", oriCol.Nullable, col.Nullable))
+	}
+	return details
+}
+
+// renameColumns returns the changes to rename the columns declared by the
+// renamed_from tags, and a copy of oriTable whose columns and indexes are
+// renamed as the database will be
+func (engine *Engine) renameColumns(table, oriTable *core.Table, tbName string) ([]*SchemaChange, *core.Table) {
+	var changes []*SchemaChange
+	var newNames = make(map[string]string)
+	for _, col := range table.Columns() {
+		oldName := engine.RenamedFrom(table, col.Name)
+		if oldName == "" || oriTable.GetColumn(col.Name) != nil {
+			continue
+		}
+		if oriCol := oriTable.GetColumn(oldName); oriCol != nil {
+			changes = append(changes, &SchemaChange{
+				Type:      SchemaRenameColumn,
+				TableName: tbName,
+				Table:     table,
+				Column:    col,
+				OldColumn: oriCol,
+				Details:   []string{"from " + oriCol.Name},
+			})
+			newNames[strings.ToLower(oriCol.Name)] = col.Name
+		}
+	}
+	if len(changes) == 0 {
+		return nil, oriTable
+	}
+
+	renamed := core.NewEmptyTable()
+	renamed.Name = oriTable.Name
+	renamed.Type = oriTable.Type
+	renamed.StoreEngine = oriTable.StoreEngine
+	renamed.Charset = oriTable.Charset
+	renamed.Comment = oriTable.Comment
+	for _, oriCol := range oriTable.Columns() {
+		if newName, ok := newNames[strings.ToLower(oriCol.Name)]; ok {
+			colCopy := *oriCol
+			colCopy.Name = newName
+			oriCol = &colCopy
+		}
+		renamed.AddColumn(oriCol)
+	}
+	for name, index := range oriTable.Indexes {
+		indexCopy := *index
+		indexCopy.Cols = make([]string, len(index.Cols))
+		for i, colName := range index.Cols {
+			if newName, ok := newNames[strings.ToLower(colName)]; ok {
+				colName = newName
+			}
+			indexCopy.Cols[i] = colName
+		}
+		renamed.Indexes[name] = &indexCopy
+	}
+	return changes, renamed
+}
+
+func (engine *Engine) diffColumns(table, oriTable *core.Table, tbName string, dropUnused bool) []*SchemaChange {
+	var changes []*SchemaChange
+	for _, col := range table.Columns() {
+		oriCol := oriTable.GetColumn(col.Name)
+		if oriCol == nil {
+			changes = append(changes, &SchemaChange{
+				Type:      SchemaAddColumn,
+				TableName: tbName,
+				Table:     table,
+				Column:    col,
+			})
+			continue
+		}
+
+		if details := engine.columnChanges(table, col, oriCol); len(details) > 0 {
+			changes = append(changes, &SchemaChange{
+				Type:      SchemaAlterColumn,
+				TableName: tbName,
+				Table:     table,
+				Column:    col,
+				OldColumn: oriCol,
+				Details:   details,
+				oriTable:  oriTable,
+			})
+		}
+	}
+
+	if !dropUnused {
+		return changes
+	}
+	for _, oriCol := range oriTable.Columns() {
+		if table.GetColumn(oriCol.Name) == nil {
+			changes = append(changes, &SchemaChange{
+				Type:      SchemaDropColumn,
+				TableName: tbName,
+				Table:     table,
+				Column:    oriCol,
+			})
+		}
+	}
+	return changes
+}
+
+func (engine *Engine) diffIndexes(table, oriTable *core.Table, tbName string) []*SchemaChange {
+	var changes []*SchemaChange
+	var foundIndexNames = make(map[string]bool)
+	for _, name := range sortedIndexNames(table.Indexes) {
+		index := table.Indexes[name]
+		var oriIndex *core.Index
+		for name2, index2 := range oriTable.Indexes {
+			if index.Equal(index2) {
+				oriIndex = index2
+				foundIndexNames[name2] = true
+				break
+			}
+		}
+
+		if oriIndex != nil && oriIndex.Type != index.Type {
+			changes = append(changes, &SchemaChange{
+				Type:      SchemaDropIndex,
+				TableName: tbName,
+				Table:     table,
+				Index:     oriIndex,
+				Details:   []string{"type changed"},
+			})
+			oriIndex = nil
+		}
+		if oriIndex == nil {
+			changes = append(changes, &SchemaChange{
+				Type:      SchemaAddIndex,
+				TableName: tbName,
+				Table:     table,
+				Index:     index,
+			})
+		}
+	}
+
+	// mysql creates the indexes of the foreign keys with the same names
+	var fkIndexNames = make(map[string]bool)
+	for _, fk := range engine.ForeignKeys(oriTable) {
+		fkIndexNames[strings.ToLower(fk.Name)] = true
+	}
+
+	for _, name2 := range sortedIndexNames(oriTable.Indexes) {
+		if !foundIndexNames[name2] && !fkIndexNames[strings.ToLower(name2)] {
+			changes = append(changes, &SchemaChange{
+				Type:      SchemaDropIndex,
+				TableName: tbName,
+				Table:     table,
+				Index:     oriTable.Indexes[name2],
+			})
+		}
+	}
+	return changes
+}
+
+func (engine *Engine) diffForeignKeys(table, oriTable *core.Table, tbName string) (drops, adds []*SchemaChange) {
+	fks := engine.tableForeignKeys(table, tbName)
+	oriFKs := engine.ForeignKeys(oriTable)
+
+	for _, oriFK := range oriFKs {
+		var found bool
+		for _, fk := range fks {
+			if fk.Equal(oriFK) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			drops = append(drops, &SchemaChange{
+				Type:       SchemaDropForeignKey,
+				TableName:  tbName,
+				Table:      table,
+				ForeignKey: oriFK,
+			})
+		}
+	}
+
+	for _, fk := range fks {
+		var found bool
+		for _, oriFK := range oriFKs {
+			if fk.Equal(oriFK) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			adds = append(adds, &SchemaChange{
+				Type:       SchemaAddForeignKey,
+				TableName:  tbName,
+				Table:      table,
+				ForeignKey: fk,
+			})
+		}
+	}
+	return drops, adds
+}
@@ -5,12 +5,16 @@
 package xorm
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/lingochamp/core"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -218,3 +222,240 @@ func TestCharst(t *testing.T) {
 		panic(err)
 	}
 }
+
+type ForeignKeyUser struct {
+	Id   int64 `xorm:"pk autoincr 'id'"`
+	Name string
+}
+
+func (ForeignKeyUser) TableName() string {
+	return "foreign_key_user"
+}
+
+type ForeignKeyPost struct {
+	Id     int64  `xorm:"pk autoincr 'id'"`
+	UserId int64  `xorm:"'user_id' index fk(foreign_key_user.id) on_delete(cascade)"`
+	Title  string `xorm:"'title'"`
+}
+
+func (ForeignKeyPost) TableName() string {
+	return "foreign_key_post"
+}
+
+func TestSyncForeignKeys(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	// the referenced table is synced after the table refers to it
+	assert.NoError(t, testEngine.Sync2(context.Background(), new(ForeignKeyPost), new(ForeignKeyUser)))
+	assert.NoError(t, testEngine.Sync2(context.Background(), new(ForeignKeyPost), new(ForeignKeyUser)))
+
+	tables, err := testEngine.DBMetas(context.Background())
+	assert.NoError(t, err)
+
+	var postTable *core.Table
+	for _, table := range tables {
+		if strings.EqualFold(table.Name, "foreign_key_post") {
+			postTable = table
+		}
+	}
+	if assert.NotNil(t, postTable) {
+		fks := testEngine.ForeignKeys(postTable)
+		if assert.EqualValues(t, 1, len(fks)) {
+			assert.True(t, strings.EqualFold("FK_foreign_key_post_user_id", fks[0].Name))
+			assert.True(t, fks[0].Equal(&ForeignKey{
+				Columns:    []string{"user_id"},
+				RefTable:   "foreign_key_user",
+				RefColumns: []string{"id"},
+				OnDelete:   "CASCADE",
+			}))
+		}
+	}
+
+	assert.NoError(t, testEngine.DropTables(context.Background(), new(ForeignKeyPost), new(ForeignKeyUser)))
+
+	assert.NoError(t, testEngine.CreateTables(context.Background(), new(ForeignKeyPost), new(ForeignKeyUser)))
+	assert.NoError(t, testEngine.DropTables(context.Background(), new(ForeignKeyPost), new(ForeignKeyUser)))
+}
+
+type SchemaDiffV1 struct {
+	Id   int64
+	Name string `xorm:"varchar(20)"`
+	Dev  int    `xorm:"index"`
+}
+
+func (SchemaDiffV1) TableName() string {
+	return "schema_diff"
+}
+
+type SchemaDiffV2 struct {
+	Id   int64
+	Name string `xorm:"varchar(20) notnull"`
+	Age  int    `xorm:"index"`
+}
+
+func (SchemaDiffV2) TableName() string {
+	return "schema_diff"
+}
+
+func TestSchemaDiff(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	diff, err := testEngine.SchemaDiff(context.Background(), new(SchemaDiffV1))
+	assert.NoError(t, err)
+	if assert.EqualValues(t, 1, len(diff.Changes)) {
+		assert.EqualValues(t, SchemaAddTable, diff.Changes[0].Type)
+	}
+
+	assert.NoError(t, testEngine.Sync2(context.Background(), new(SchemaDiffV1)))
+
+	// the unused columns are not dropped by Sync2 by default
+	diff, err = testEngine.SchemaDiff(context.Background(), new(SchemaDiffV2))
+	assert.NoError(t, err)
+	for _, change := range diff.Changes {
+		assert.NotEqual(t, SchemaDropColumn, change.Type)
+	}
+
+	diff, err = testEngine.DropUnused(false).SchemaDiff(context.Background(), new(SchemaDiffV2))
+	assert.NoError(t, err)
+
+	var types = make(map[SchemaChangeType]int)
+	for _, change := range diff.Changes {
+		types[change.Type]++
+	}
+	assert.EqualValues(t, 1, types[SchemaAddColumn])
+	assert.EqualValues(t, 1, types[SchemaDropColumn])
+	assert.EqualValues(t, 1, types[SchemaAlterColumn])
+	assert.EqualValues(t, 1, types[SchemaAddIndex])
+	assert.EqualValues(t, 1, types[SchemaDropIndex])
+
+	sqls, err := diff.SQLs()
+	assert.NoError(t, err)
+	assert.True(t, len(sqls) >= len(diff.Changes))
+
+	var buf bytes.Buffer
+	assert.NoError(t, testEngine.DryRun(&buf).Sync2(context.Background(), new(SchemaDiffV2)))
+	assert.True(t, strings.Contains(buf.String(), "ALTER TABLE"))
+
+	// the dry run doesn't change the database
+	diff2, err := testEngine.DropUnused(false).SchemaDiff(context.Background(), new(SchemaDiffV2))
+	assert.NoError(t, err)
+	assert.EqualValues(t, len(diff.Changes), len(diff2.Changes))
+}
+
+type AlterColumnV1 struct {
+	Id     int64
+	Name   string `xorm:"varchar(20)"`
+	Amount int    `xorm:"int"`
+	Status int    `xorm:"default(0)"`
+}
+
+func (AlterColumnV1) TableName() string {
+	return "alter_column"
+}
+
+type AlterColumnV2 struct {
+	Id     int64
+	Name   string `xorm:"varchar(40) notnull"`
+	Amount int64  `xorm:"bigint"`
+	Status int    `xorm:"default(1)"`
+	Kind   string `xorm:"varchar(20) default('normal')"`
+	Level  int    `xorm:"default(-1)"`
+}
+
+func (AlterColumnV2) TableName() string {
+	return "alter_column"
+}
+
+func TestSyncAlterColumn(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.Sync2(context.Background(), new(AlterColumnV1)))
+
+	_, err := testEngine.Insert(context.Background(), &AlterColumnV1{Name: "lunny", Amount: 10})
+	assert.NoError(t, err)
+
+	assert.NoError(t, testEngine.Sync2(context.Background(), new(AlterColumnV2)))
+
+	tables, err := testEngine.DBMetas(context.Background())
+	assert.NoError(t, err)
+	for _, table := range tables {
+		if table.Name != "alter_column" {
+			continue
+		}
+		col := table.GetColumn("name")
+		if assert.NotNil(t, col) {
+			assert.False(t, col.Nullable)
+		}
+	}
+
+	var bean AlterColumnV2
+	has, err := testEngine.Get(context.Background(), &bean)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "lunny", bean.Name)
+	assert.EqualValues(t, 10, bean.Amount)
+
+	// the defaults read from the database are the same as the structs
+	diff, err := testEngine.SchemaDiff(context.Background(), new(AlterColumnV2))
+	assert.NoError(t, err)
+	assert.True(t, diff.IsEmpty(), "%v", diff.Changes)
+
+	var buf bytes.Buffer
+	assert.NoError(t, testEngine.DryRun(&buf).Sync2(context.Background(), new(AlterColumnV2)))
+	assert.EqualValues(t, "", buf.String())
+}
+
+type RenameColumnV1 struct {
+	Id    int64
+	Name  string `xorm:"'name' varchar(20) index"`
+	Extra string `xorm:"'extra'"`
+}
+
+func (RenameColumnV1) TableName() string {
+	return "rename_column"
+}
+
+type RenameColumnV2 struct {
+	Id       int64
+	Nickname string `xorm:"'nickname' varchar(20) index renamed_from(name)"`
+}
+
+func (RenameColumnV2) TableName() string {
+	return "rename_column"
+}
+
+func TestSyncRenameAndDropColumn(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assert.NoError(t, testEngine.Sync2(context.Background(), new(RenameColumnV1)))
+
+	_, err := testEngine.Insert(context.Background(), &RenameColumnV1{Name: "lunny", Extra: "extra"})
+	assert.NoError(t, err)
+
+	diff, err := testEngine.DropUnused(false).SchemaDiff(context.Background(), new(RenameColumnV2))
+	assert.NoError(t, err)
+	var types = make(map[SchemaChangeType]int)
+	for _, change := range diff.Changes {
+		types[change.Type]++
+	}
+	assert.EqualValues(t, 1, types[SchemaRenameColumn])
+	assert.EqualValues(t, 1, types[SchemaDropColumn])
+	assert.EqualValues(t, 0, types[SchemaAddColumn])
+
+	// the column with data is not dropped unless it's forced
+	err = testEngine.DropUnused(false).Sync2(context.Background(), new(RenameColumnV2))
+	assert.True(t, errors.Is(err, ErrColumnNotEmpty))
+
+	assert.NoError(t, testEngine.DropUnused(true).Sync2(context.Background(), new(RenameColumnV2)))
+
+	var bean RenameColumnV2
+	has, err := testEngine.Get(context.Background(), &bean)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, "lunny", bean.Nickname)
+
+	diff, err = testEngine.DropUnused(false).SchemaDiff(context.Background(), new(RenameColumnV2))
+	assert.NoError(t, err)
+	for _, change := range diff.Changes {
+		assert.NotEqual(t, SchemaRenameColumn, change.Type)
+		assert.NotEqual(t, SchemaDropColumn, change.Type)
+	}
+}
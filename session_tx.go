@@ -4,36 +4,311 @@
 
 package xorm
 
-// Begin a transaction
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"sync/atomic"
+
+	"github.com/lingochamp/core"
+)
+
+// txOptionsDialect is implemented by the dialects which could tell which
+// transaction options are supported by the database
+type txOptionsDialect interface {
+	SupportIsolationLevel(level sql.IsolationLevel) bool
+	SupportReadOnlyTx() bool
+}
+
+// savepointDialect is implemented by the dialects whose savepoint syntax
+// differs from the standard SAVEPOINT, ROLLBACK TO SAVEPOINT and RELEASE
+// SAVEPOINT statements. An empty release SQL means savepoints could not be
+// released explicitly.
+type savepointDialect interface {
+	SavepointSQL(name string) string
+	RollbackToSavepointSQL(name string) string
+	ReleaseSavepointSQL(name string) string
+}
+
+// setTransactionDialect is implemented by the dialects which could set the
+// options of the current transaction by a statement, which is used when the
+// driver could not begin transactions with the options
+type setTransactionDialect interface {
+	SetTransactionSql(opts *sql.TxOptions) string
+}
+
+// resetTransactionDialect is implemented by the dialects whose options set by
+// SetTransactionSql are kept on the connection after the transaction, they are
+// restored before the transaction is committed or rolled back
+type resetTransactionDialect interface {
+	ResetTransactionSql() string
+}
+
+var savepointNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Begin a transaction. If the session is already in a transaction, a
+// savepoint will be created and the matching Commit or Rollback will
+// release or rollback to it.
 func (session *Session) Begin() error {
+	return session.BeginTx(context.Background(), nil)
+}
+
+// BeginTx begin a transaction with the context and options. The context is
+// used until the transaction is committed or rolled back, statements
+// executed within the transaction without a cancelable context will use it.
+// If the session is already in a transaction, a savepoint will be created
+// and ctx and opts are ignored.
+func (session *Session) BeginTx(ctx context.Context, opts *sql.TxOptions) error {
+	if session.inTx() {
+		name := fmt.Sprintf("xorm_sp_%d", len(session.savepoints)+1)
+		if err := session.Savepoint(name); err != nil {
+			return err
+		}
+		session.savepoints = append(session.savepoints, name)
+		return nil
+	}
+
 	if session.isAutoCommit {
-		tx, err := session.DB().Begin()
+		if err := session.checkTxOptions(opts); err != nil {
+			return err
+		}
+
+		var setTxSQLs []string
+		var resetTxSQL string
+		if hasTxOptions(opts) && !session.engine.driverSupportsTxOptions(ctx) {
+			dialect, ok := session.engine.dialect.(setTransactionDialect)
+			if !ok {
+				return fmt.Errorf("the driver of %v does not support transaction options", session.engine.dialect.DBType())
+			}
+			setTxSQLs = []string{dialect.SetTransactionSql(opts)}
+			if session.engine.dialect.DBType() == core.MYSQL {
+				// SET TRANSACTION applies to the next transaction, so the one
+				// begun by the driver is ended and another is started on the
+				// same connection
+				setTxSQLs = []string{"COMMIT", setTxSQLs[0], "START TRANSACTION"}
+			}
+			if dialect, ok := session.engine.dialect.(resetTransactionDialect); ok {
+				resetTxSQL = dialect.ResetTransactionSql()
+			}
+			opts = nil
+		}
+
+		tx, err := session.DB().BeginTx(ctx, opts)
 		if err != nil {
 			return err
 		}
+		for _, sqlStr := range setTxSQLs {
+			if _, err := tx.ExecContext(ctx, sqlStr); err != nil {
+				tx.Rollback()
+				return session.engine.classifyError(err)
+			}
+		}
+		session.resetTxSQL = resetTxSQL
 		session.isAutoCommit = false
 		session.isCommitedOrRollbacked = false
 		session.tx = tx
+		session.txCtx = ctx
 		session.saveLastSQL("BEGIN TRANSACTION")
 	}
 	return nil
 }
 
-// Rollback When using transaction, you can rollback if any error
+// resetTxOptions restores the options of the connection which are set by
+// SetTransactionSql and kept after the transaction
+func (session *Session) resetTxOptions() error {
+	if session.resetTxSQL == "" {
+		return nil
+	}
+	sqlStr := session.resetTxSQL
+	session.resetTxSQL = ""
+	session.saveLastSQL(sqlStr)
+	_, err := session.tx.ExecContext(session.txContext(context.Background()), sqlStr)
+	return session.engine.classifyError(err)
+}
+
+func hasTxOptions(opts *sql.TxOptions) bool {
+	return opts != nil && (opts.Isolation != sql.LevelDefault || opts.ReadOnly)
+}
+
+// driverSupportsTxOptions reports whether the connections of the driver begin
+// transactions with the options, database/sql rejects the options otherwise
+func (engine *Engine) driverSupportsTxOptions(ctx context.Context) bool {
+	switch atomic.LoadInt32(&engine.driverTxOptions) {
+	case 1:
+		return true
+	case 2:
+		return false
+	}
+
+	conn, err := engine.DB().Conn(ctx)
+	if err != nil {
+		// let database/sql report the error
+		return true
+	}
+	defer conn.Close()
+
+	var supported bool
+	if err := conn.Raw(func(driverConn interface{}) error {
+		_, supported = driverConn.(driver.ConnBeginTx)
+		return nil
+	}); err != nil {
+		return true
+	}
+	if supported {
+		atomic.StoreInt32(&engine.driverTxOptions, 1)
+	} else {
+		atomic.StoreInt32(&engine.driverTxOptions, 2)
+	}
+	return supported
+}
+
+func (session *Session) checkTxOptions(opts *sql.TxOptions) error {
+	if opts == nil {
+		return nil
+	}
+
+	dialect, ok := session.engine.dialect.(txOptionsDialect)
+	if !ok {
+		if hasTxOptions(opts) {
+			return fmt.Errorf("%v does not support transaction options", session.engine.dialect.DBType())
+		}
+		return nil
+	}
+
+	if !dialect.SupportIsolationLevel(opts.Isolation) {
+		return fmt.Errorf("%v does not support isolation level %v", session.engine.dialect.DBType(), opts.Isolation)
+	}
+	if opts.ReadOnly && !dialect.SupportReadOnlyTx() {
+		return fmt.Errorf("%v does not support read-only transaction", session.engine.dialect.DBType())
+	}
+	return nil
+}
+
+// txContext returns the context of the current transaction if ctx could not
+// be canceled, so that statements in a transaction follow the transaction.
+func (session *Session) txContext(ctx context.Context) context.Context {
+	if session.isAutoCommit || session.txCtx == nil {
+		return ctx
+	}
+	if ctx == nil || ctx.Done() == nil {
+		return session.txCtx
+	}
+	return ctx
+}
+
+func (session *Session) inTx() bool {
+	return !session.isAutoCommit && !session.isCommitedOrRollbacked
+}
+
+// beginImplicitTx begins a transaction for the statements which should be
+// atomic if the session isn't in a transaction. The returned func commits the
+// transaction, or rolls it back if *err is not nil, and restores autocommit so
+// that the session could be reused.
+func (session *Session) beginImplicitTx(ctx context.Context) (func(err *error), error) {
+	if session.inTx() {
+		return func(*error) {}, nil
+	}
+	if err := session.BeginTx(ctx, nil); err != nil {
+		return nil, err
+	}
+	return func(err *error) {
+		if *err != nil {
+			session.Rollback()
+		} else {
+			*err = session.Commit()
+		}
+		session.isAutoCommit = true
+	}, nil
+}
+
+func (session *Session) execSavepoint(sqlStr string) error {
+	if !session.inTx() {
+		return ErrNotInTransaction
+	}
+	if len(sqlStr) == 0 {
+		return nil
+	}
+
+	ctx := session.txContext(context.Background())
+	session.saveLastSQL(sqlStr)
+	_, err := session.tx.ExecContext(ctx, sqlStr)
+	return session.engine.classifyError(err)
+}
+
+// Savepoint creates a savepoint with the name in the current transaction
+func (session *Session) Savepoint(name string) error {
+	if !savepointNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	if dialect, ok := session.engine.dialect.(savepointDialect); ok {
+		return session.execSavepoint(dialect.SavepointSQL(name))
+	}
+	return session.execSavepoint("SAVEPOINT " + name)
+}
+
+// RollbackTo rolls back the current transaction to the savepoint with the name,
+// the transaction is still active after that.
+func (session *Session) RollbackTo(name string) error {
+	if !savepointNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	if dialect, ok := session.engine.dialect.(savepointDialect); ok {
+		return session.execSavepoint(dialect.RollbackToSavepointSQL(name))
+	}
+	return session.execSavepoint("ROLLBACK TO SAVEPOINT " + name)
+}
+
+// ReleaseSavepoint releases the savepoint with the name. On the databases
+// which could not release savepoints it does nothing.
+func (session *Session) ReleaseSavepoint(name string) error {
+	if !savepointNameRegexp.MatchString(name) {
+		return fmt.Errorf("invalid savepoint name %q", name)
+	}
+	if dialect, ok := session.engine.dialect.(savepointDialect); ok {
+		return session.execSavepoint(dialect.ReleaseSavepointSQL(name))
+	}
+	return session.execSavepoint("RELEASE SAVEPOINT " + name)
+}
+
+// Rollback When using transaction, you can rollback if any error. In a
+// nested transaction it rolls back to the savepoint created by the matching
+// Begin.
 func (session *Session) Rollback() error {
+	if n := len(session.savepoints); n > 0 && session.inTx() {
+		name := session.savepoints[n-1]
+		session.savepoints = session.savepoints[:n-1]
+		return session.RollbackTo(name)
+	}
+
 	if !session.isAutoCommit && !session.isCommitedOrRollbacked {
+		// the transaction is rolled back whether the options are reset or not
+		session.resetTxOptions()
 		session.saveLastSQL(session.engine.dialect.RollBackStr())
 		session.isCommitedOrRollbacked = true
+		session.txCtx = nil
 		return session.tx.Rollback()
 	}
 	return nil
 }
 
-// Commit When using transaction, Commit will commit all operations.
+// Commit When using transaction, Commit will commit all operations. In a
+// nested transaction it releases the savepoint created by the matching Begin.
 func (session *Session) Commit() error {
+	if n := len(session.savepoints); n > 0 && session.inTx() {
+		name := session.savepoints[n-1]
+		session.savepoints = session.savepoints[:n-1]
+		return session.ReleaseSavepoint(name)
+	}
+
 	if !session.isAutoCommit && !session.isCommitedOrRollbacked {
+		if err := session.resetTxOptions(); err != nil {
+			return err
+		}
 		session.saveLastSQL("COMMIT")
 		session.isCommitedOrRollbacked = true
+		session.txCtx = nil
 		var err error
 		if err = session.tx.Commit(); err == nil {
 			// handle processors after tx committed
@@ -75,7 +350,7 @@ func (session *Session) Commit() error {
 			cleanUpFunc(&session.afterUpdateBeans)
 			cleanUpFunc(&session.afterDeleteBeans)
 		}
-		return err
+		return session.engine.classifyError(err)
 	}
 	return nil
 }
@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"testing"
 	"time"
@@ -191,3 +192,107 @@ func TestCombineTransactionSameMapper(t *testing.T) {
 		panic(err)
 	}
 }
+
+func TestBeginTx(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(Userinfo))
+
+	session := testEngine.NewSession()
+	defer session.Close()
+
+	err := session.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelLinearizable})
+	assert.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	err = session.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if _, ok := testEngine.Dialect().(setTransactionDialect); !ok && err != nil {
+		// neither the driver nor the dialect could set the isolation level
+		assert.Contains(t, err.Error(), "does not support transaction options")
+		assert.NoError(t, session.BeginTx(ctx, nil))
+	} else {
+		assert.NoError(t, err)
+	}
+
+	user := Userinfo{Username: "begintx", Departname: "dev", Alias: "lunny", Created: time.Now()}
+	_, err = session.Insert(context.Background(), &user)
+	assert.NoError(t, err)
+	assert.NoError(t, session.Commit())
+
+	has, err := testEngine.Exist(context.Background(), &Userinfo{Username: "begintx"})
+	assert.NoError(t, err)
+	assert.True(t, has)
+
+	session2 := testEngine.NewSession()
+	defer session2.Close()
+
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	assert.NoError(t, session2.BeginTx(ctx2, nil))
+	cancel2()
+
+	_, err = session2.Insert(context.Background(), &Userinfo{Username: "begintx2"})
+	assert.Error(t, err)
+}
+
+func TestBeginTxReadOnly(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(Userinfo))
+
+	dialect, ok := testEngine.Dialect().(txOptionsDialect)
+	if !ok || !dialect.SupportReadOnlyTx() {
+		t.Skip("read-only transactions are not supported")
+	}
+
+	session := testEngine.NewSession()
+	defer session.Close()
+
+	// the options are set by the driver or by SET TRANSACTION of the dialect
+	ctx := context.Background()
+	assert.NoError(t, session.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}))
+	_, err := session.Insert(ctx, &Userinfo{Username: "readonly"})
+	assert.Error(t, err)
+	assert.NoError(t, session.Rollback())
+
+	has, err := testEngine.Exist(ctx, &Userinfo{Username: "readonly"})
+	assert.NoError(t, err)
+	assert.False(t, has)
+}
+
+func TestNestedTransaction(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+	assertSync(t, new(Userinfo))
+
+	session := testEngine.NewSession()
+	defer session.Close()
+
+	assert.Equal(t, ErrNotInTransaction, session.Savepoint("sp1"))
+
+	assert.NoError(t, session.Begin())
+	_, err := session.Insert(context.Background(), &Userinfo{Username: "outer"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, session.Begin())
+	_, err = session.Insert(context.Background(), &Userinfo{Username: "inner"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Rollback())
+
+	assert.NoError(t, session.Begin())
+	_, err = session.Insert(context.Background(), &Userinfo{Username: "inner2"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.Commit())
+
+	assert.NoError(t, session.Savepoint("sp1"))
+	_, err = session.Insert(context.Background(), &Userinfo{Username: "inner3"})
+	assert.NoError(t, err)
+	assert.NoError(t, session.RollbackTo("sp1"))
+	assert.NoError(t, session.ReleaseSavepoint("sp1"))
+	assert.Error(t, session.Savepoint("sp1; DROP TABLE userinfo"))
+
+	assert.NoError(t, session.Commit())
+
+	for name, exist := range map[string]bool{"outer": true, "inner": false, "inner2": true, "inner3": false} {
+		has, err := testEngine.Exist(context.Background(), &Userinfo{Username: name})
+		assert.NoError(t, err)
+		assert.EqualValues(t, exist, has, name)
+	}
+}
@@ -6,6 +6,7 @@ package xorm
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"reflect"
@@ -177,10 +178,13 @@ func (session *Session) Update(ctx context.Context, bean interface{}, condiBean
 		}
 
 		if session.statement.ColumnStr == "" {
-			colNames, args = buildUpdates(session.engine, session.statement.RefTable, bean, false, false,
+			colNames, args, err = buildUpdates(session.engine, session.statement.RefTable, bean, false, false,
 				false, false, session.statement.allUseBool, session.statement.useAllCols,
 				session.statement.mustColumnMap, session.statement.nullableMap,
 				session.statement.columnMap, true, session.statement.unscoped)
+			if err != nil {
+				return 0, err
+			}
 		} else {
 			colNames, args, err = genCols(session.statement.RefTable, session, bean, true, true)
 			if err != nil {
@@ -348,16 +352,39 @@ func (session *Session) Update(ctx context.Context, bean interface{}, condiBean
 		return 0, errors.New("No content found to be updated")
 	}
 
-	sqlStr = fmt.Sprintf("UPDATE %v%v SET %v %v",
+	var isReturning = len(session.statement.returning) > 0
+	if isReturning && !isStruct {
+		return 0, errors.New("Returning needs a struct bean to be updated")
+	}
+	// the returned version has been increased already
+	var versionReturned = isReturning && table != nil && table.Version != "" &&
+		(containsColumn(session.statement.returning, "*") ||
+			containsColumn(session.statement.returning, table.Version))
+	output, returning, err := session.statement.genReturning("INSERTED")
+	if err != nil {
+		return 0, err
+	}
+
+	sqlStr = fmt.Sprintf("UPDATE %v%v SET %v%v %v%v",
 		top,
 		session.engine.Quote(tableName),
 		strings.Join(colNames, ", "),
-		condSQL)
+		output,
+		condSQL,
+		returning)
 
-	res, err := session.exec(ctx, sqlStr, append(args, condArgs...)...)
+	var affected int64
+	if isReturning {
+		affected, err = session.queryReturningBean(ctx, bean, sqlStr, append(args, condArgs...)...)
+	} else {
+		var res sql.Result
+		if res, err = session.exec(ctx, sqlStr, append(args, condArgs...)...); err == nil {
+			affected, err = res.RowsAffected()
+		}
+	}
 	if err != nil {
 		return 0, err
-	} else if doIncVer {
+	} else if doIncVer && !versionReturned {
 		if verValue != nil && verValue.IsValid() && verValue.CanSet() {
 			verValue.SetInt(verValue.Int() + 1)
 		}
@@ -401,5 +428,5 @@ func (session *Session) Update(ctx context.Context, bean interface{}, condiBean
 	cleanupProcessorsClosures(&session.afterClosures) // cleanup after used
 	// --
 
-	return res.RowsAffected()
+	return affected, nil
 }
@@ -0,0 +1,131 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"strings"
+
+	"github.com/lingochamp/core"
+)
+
+// SplitSQL splits the SQL script into statements by the semicolons which are
+// not in quoted strings, quoted identifiers, comments or dollar-quoted bodies
+// of Postgres. The statements which have comments only are skipped, except
+// the executable comments of MySQL, e.g. /*!40101 SET NAMES utf8 */. The
+// backslash escapes of the strings depend on the database type.
+func SplitSQL(dbType core.DbType, script string) []string {
+	var stmts []string
+	var start int
+	var hasCode bool
+
+	add := func(end int) {
+		stmt := strings.TrimSpace(script[start:end])
+		if stmt != "" && hasCode {
+			stmts = append(stmts, stmt)
+		}
+		hasCode = false
+	}
+
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == ';':
+			add(i)
+			start = i + 1
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(script, i, backslashEscapes(dbType, script, i))
+			hasCode = true
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			if end := strings.IndexByte(script[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(script)
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			if strings.HasPrefix(script[i:], "/*!") {
+				hasCode = true
+			}
+			if end := strings.Index(script[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(script)
+			}
+		case c == '$':
+			if tag := dollarQuoteTag(script[i:]); tag != "" {
+				if end := strings.Index(script[i+len(tag):], tag); end >= 0 {
+					i += len(tag) + end + len(tag) - 1
+				} else {
+					i = len(script)
+				}
+			}
+			hasCode = true
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			hasCode = true
+		}
+	}
+	if start < len(script) {
+		add(len(script))
+	}
+	return stmts
+}
+
+// skipQuoted returns the index of the closing quote of the quoted string or
+// identifier starting at i. The quote is escaped by doubling it, or by a
+// backslash if backslash is true.
+func skipQuoted(script string, i int, backslash bool) int {
+	quote := script[i]
+	for i++; i < len(script); i++ {
+		switch script[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(script) && script[i+1] == quote {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return len(script)
+}
+
+// backslashEscapes reports whether the backslashes are escapes in the quoted
+// string starting at i, which are the strings of MySQL and the escape strings
+// of Postgres, e.g. E'it\'s'. The other strings end at the first quote, e.g.
+// 'C:\'.
+func backslashEscapes(dbType core.DbType, script string, i int) bool {
+	switch dbType {
+	case core.MYSQL:
+		return script[i] != '`'
+	case core.POSTGRES:
+		if script[i] != '\'' || i == 0 || script[i-1] != 'E' && script[i-1] != 'e' {
+			return false
+		}
+		return i == 1 || !isIdentByte(script[i-2])
+	}
+	return false
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// dollarQuoteTag returns the opening tag of a dollar-quoted string of
+// Postgres, e.g. $$ or $body$, or an empty string if it's not a tag
+func dollarQuoteTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
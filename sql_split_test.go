@@ -0,0 +1,40 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/lingochamp/core"
+)
+
+func TestSplitSQL(t *testing.T) {
+	var cases = []struct {
+		dbType core.DbType
+		script string
+		stmts  []string
+	}{
+		{core.MYSQL, "CREATE TABLE a (id INT); INSERT INTO a VALUES (1)", []string{"CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"}},
+		{core.MYSQL, "INSERT INTO a VALUES ('a;b', 'it''s', 'x\\';y');", []string{"INSERT INTO a VALUES ('a;b', 'it''s', 'x\\';y')"}},
+		{core.MYSQL, "SELECT \"a;b\" FROM `c;d`;", []string{"SELECT \"a;b\" FROM `c;d`"}},
+		{core.MYSQL, "-- comment; here\nSELECT 1;\n-- trailing comment", []string{"-- comment; here\nSELECT 1"}},
+		{core.MYSQL, "/* only; comment */; /*!40101 SET NAMES utf8 */;", []string{"/*!40101 SET NAMES utf8 */"}},
+		{core.POSTGRES, "CREATE FUNCTION f() RETURNS INT AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql; SELECT $1",
+			[]string{"CREATE FUNCTION f() RETURNS INT AS $body$ BEGIN RETURN 1; END; $body$ LANGUAGE plpgsql", "SELECT $1"}},
+		{core.MYSQL, " ;\n; ", nil},
+		// the backslashes only escape in the strings of MySQL and the escape strings of Postgres
+		{core.POSTGRES, "INSERT INTO a VALUES ('C:\\'); SELECT E'x\\';y', type'z\\';", []string{"INSERT INTO a VALUES ('C:\\')", "SELECT E'x\\';y', type'z\\'"}},
+		{core.SQLITE, "INSERT INTO a VALUES ('C:\\'); SELECT 1", []string{"INSERT INTO a VALUES ('C:\\')", "SELECT 1"}},
+		{core.MSSQL, "INSERT INTO a VALUES (N'C:\\'); SELECT 1", []string{"INSERT INTO a VALUES (N'C:\\')", "SELECT 1"}},
+	}
+
+	for _, kase := range cases {
+		stmts := SplitSQL(kase.dbType, kase.script)
+		if !reflect.DeepEqual(stmts, kase.stmts) {
+			t.Fatalf("%q is split into [%d]%q, expected [%d]%q", kase.script, len(stmts), stmts, len(kase.stmts), kase.stmts)
+		}
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -34,6 +35,12 @@ type exprParam struct {
 	expr    string
 }
 
+type conflictParam struct {
+	columns    []string
+	updateCols []string
+	doNothing  bool
+}
+
 // Statement save all the sql info for executing SQL
 type Statement struct {
 	RefTable        *core.Table
@@ -75,6 +82,13 @@ type Statement struct {
 	exprColumns     map[string]exprParam
 	cond            builder.Cond
 	bufferSize      int
+	conflict        *conflictParam
+	returning       []string
+	preloads        []string
+	derived         *subQuery
+	ctes            []commonTableExpr
+	setOps          []setOperation
+	cursor          string
 }
 
 // Init reset all the statement's fields
@@ -114,6 +128,13 @@ func (statement *Statement) Init() {
 	statement.exprColumns = make(map[string]exprParam)
 	statement.cond = builder.NewCond()
 	statement.bufferSize = 0
+	statement.conflict = nil
+	statement.returning = nil
+	statement.preloads = nil
+	statement.derived = nil
+	statement.ctes = nil
+	statement.setOps = nil
+	statement.cursor = ""
 }
 
 // NoAutoCondition if you do not want convert bean's field as query condition, then use this function
@@ -131,6 +152,153 @@ func (statement *Statement) Alias(alias string) *Statement {
 	return statement
 }
 
+// commonTableExpr is a named subquery of the WITH clause
+type commonTableExpr struct {
+	name      string
+	recursive bool
+	query     *subQuery
+}
+
+// With adds a common table expression which is named name, the query could be
+// a *Session, a *builder.Builder or a string with args
+func (statement *Statement) With(name string, query interface{}, args ...interface{}) *Statement {
+	return statement.with(name, false, query, args)
+}
+
+// WithRecursive adds a recursive common table expression, the query is usually
+// a string which unions the initial rows and the rows referencing the name
+func (statement *Statement) WithRecursive(name string, query interface{}, args ...interface{}) *Statement {
+	return statement.with(name, true, query, args)
+}
+
+func (statement *Statement) with(name string, recursive bool, query interface{}, args []interface{}) *Statement {
+	sub, ok := newSubQuery(query)
+	if !ok {
+		if sqlStr, isStr := query.(string); isStr {
+			sub = &subQuery{sql: sqlStr, args: args}
+		} else {
+			sub = &subQuery{err: fmt.Errorf("unsupported subquery type %T", query)}
+		}
+	}
+	statement.ctes = append(statement.ctes, commonTableExpr{name, recursive, sub})
+	statement.UseCache = false
+	return statement
+}
+
+// genWithSQL generates the WITH clause, the name could have the column list,
+// e.g. tree(id, parent_id), which is not quoted
+func (statement *Statement) genWithSQL() (string, error) {
+	var recursive bool
+	var ctes = make([]string, 0, len(statement.ctes))
+	for _, cte := range statement.ctes {
+		if cte.query.err != nil {
+			return "", cte.query.err
+		}
+		recursive = recursive || cte.recursive
+
+		name := cte.name
+		if !strings.Contains(name, " ") && !strings.Contains(name, "(") {
+			name = statement.Engine.Quote(name)
+		}
+		ctes = append(ctes, fmt.Sprintf("%s AS (%s)", name, cte.query.sql))
+	}
+
+	var dbType = statement.Engine.Dialect().DBType()
+	if recursive && dbType != core.MSSQL && dbType != core.ORACLE {
+		return "WITH RECURSIVE " + strings.Join(ctes, ", ") + " ", nil
+	}
+	return "WITH " + strings.Join(ctes, ", ") + " ", nil
+}
+
+// setOperation is a query combined with the statement by UNION, UNION ALL,
+// INTERSECT or EXCEPT
+type setOperation struct {
+	operator string
+	query    *subQuery
+}
+
+// combine combines the results of the session by the set operator, the ORDER
+// BY and LIMIT of the statement are applied to the compound query
+func (statement *Statement) combine(operator string, other *Session) *Statement {
+	var query = &subQuery{err: fmt.Errorf("%s needs a query to be combined", operator)}
+	if other != nil {
+		query, _ = newSubQuery(other)
+	}
+	statement.setOps = append(statement.setOps, setOperation{operator, query})
+	statement.UseCache = false
+	return statement
+}
+
+// compoundColumnStr returns the columns of the first query of the compound
+// query, which are counted or summed by the outer query
+func (statement *Statement) compoundColumnStr() string {
+	if statement.ColumnStr != "" {
+		return statement.ColumnStr
+	}
+	if columnStr := statement.genColumnStr(); columnStr != "" {
+		return columnStr
+	}
+	return "*"
+}
+
+// genCompoundSQL generates the compound query of the statement and the set
+// operations, which is selected as a derived table so that the ORDER BY and
+// the paging of all the dialects are applied to the whole results
+func (statement *Statement) genCompoundSQL(operandColumnStr, columnStr, condSQL string) (string, error) {
+	operand := *statement
+	operand.setOps = nil
+	operand.ctes = nil
+	operand.OrderStr = ""
+	operand.Start, operand.LimitN = 0, 0
+	operand.IsForUpdate = false
+	sqlStr, err := operand.genSelectSQL(operandColumnStr, condSQL)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(sqlStr)
+	for i, op := range statement.setOps {
+		if op.query.err != nil {
+			return "", op.query.err
+		}
+		var operator = op.operator
+		if operator == "EXCEPT" && statement.Engine.Dialect().DBType() == core.ORACLE {
+			operator = "MINUS"
+		}
+		// the other queries are selected as derived tables since their ORDER BY
+		// and LIMIT are not allowed by some databases in a compound query
+		fmt.Fprintf(&buf, " %s SELECT * FROM (%s) %s", operator, op.query.sql,
+			statement.Engine.Quote(fmt.Sprintf("xorm_operand%d", i+1)))
+	}
+
+	outer := *statement
+	outer.setOps = nil
+	outer.derived = &subQuery{sql: buf.String()}
+	outer.TableAlias = "xorm_compound"
+	outer.JoinStr = ""
+	outer.GroupByStr, outer.HavingStr = "", ""
+	outer.IsDistinct = false
+	if statement.Engine.Dialect().DBType() != core.MSSQL || statement.Start == 0 {
+		return outer.genSelectSQL(columnStr, "")
+	}
+
+	// the offset of mssql by NOT IN (SELECT TOP ...) needs a key column of the
+	// table, the compound results are paged by OFFSET FETCH instead
+	outer.Start, outer.LimitN = 0, 0
+	if outer.OrderStr == "" {
+		outer.OrderStr = "(SELECT NULL)"
+	}
+	if sqlStr, err = outer.genSelectSQL(columnStr, ""); err != nil {
+		return "", err
+	}
+	sqlStr += fmt.Sprintf(" OFFSET %d ROWS", statement.Start)
+	if statement.LimitN > 0 {
+		sqlStr += fmt.Sprintf(" FETCH NEXT %d ROWS ONLY", statement.LimitN)
+	}
+	return sqlStr, nil
+}
+
 // SQL adds raw sql statement
 func (statement *Statement) SQL(query interface{}, args ...interface{}) *Statement {
 	switch query.(type) {
@@ -202,20 +370,211 @@ func (statement *Statement) Or(query interface{}, args ...interface{}) *Statemen
 	return statement
 }
 
-// In generate "Where column IN (?) " statement
+// In generate "Where column IN (?) " statement, the only arg could be a
+// *Session or a *builder.Builder as a subquery
 func (statement *Statement) In(column string, args ...interface{}) *Statement {
-	in := builder.In(statement.Engine.Quote(column), args...)
+	if len(args) == 1 {
+		if query, ok := newSubQuery(args[0]); ok {
+			statement.cond = statement.cond.And(&subQueryCond{statement.Engine.Quote(column) + " IN ", query})
+			return statement
+		}
+	}
+	in := statement.genIn(statement.Engine.Quote(column), false, args)
 	statement.cond = statement.cond.And(in)
 	return statement
 }
 
-// NotIn generate "Where column NOT IN (?) " statement
+// NotIn generate "Where column NOT IN (?) " statement, the only arg could be a
+// *Session or a *builder.Builder as a subquery
 func (statement *Statement) NotIn(column string, args ...interface{}) *Statement {
-	notIn := builder.NotIn(statement.Engine.Quote(column), args...)
+	if len(args) == 1 {
+		if query, ok := newSubQuery(args[0]); ok {
+			statement.cond = statement.cond.And(&subQueryCond{statement.Engine.Quote(column) + " NOT IN ", query})
+			return statement
+		}
+	}
+	notIn := statement.genIn(statement.Engine.Quote(column), true, args)
 	statement.cond = statement.cond.And(notIn)
 	return statement
 }
 
+// Exists generate "Where EXISTS (subquery)" statement, the subquery is a
+// *Session or a *builder.Builder
+func (statement *Statement) Exists(sub interface{}) *Statement {
+	return statement.exists("EXISTS ", sub)
+}
+
+// NotExists generate "Where NOT EXISTS (subquery)" statement
+func (statement *Statement) NotExists(sub interface{}) *Statement {
+	return statement.exists("NOT EXISTS ", sub)
+}
+
+func (statement *Statement) exists(prefix string, sub interface{}) *Statement {
+	query, ok := newSubQuery(sub)
+	if !ok {
+		query = &subQuery{err: fmt.Errorf("unsupported subquery type %T", sub)}
+	}
+	statement.cond = statement.cond.And(&subQueryCond{prefix, query})
+	return statement
+}
+
+// subQuery is the SQL of a *Session or a *builder.Builder used as a subquery
+// or a derived table. It's generated when it's passed, and the error is
+// returned when the outer statement is generated.
+type subQuery struct {
+	sql  string
+	args []interface{}
+	err  error
+}
+
+func newSubQuery(sub interface{}) (*subQuery, bool) {
+	var query subQuery
+	switch sub := sub.(type) {
+	case *Session:
+		query.sql, query.args, query.err = sub.genQuerySQL()
+	case *builder.Builder:
+		query.sql, query.args, query.err = sub.ToSQL()
+	default:
+		return nil, false
+	}
+	return &query, true
+}
+
+// subQueryCond is the condition of a subquery, e.g. EXISTS (subquery), the
+// args of the subquery are written in the order of the placeholders
+type subQueryCond struct {
+	prefix string
+	query  *subQuery
+}
+
+var _ builder.Cond = &subQueryCond{}
+
+func (cond *subQueryCond) WriteTo(w builder.Writer) error {
+	if cond.query.err != nil {
+		return cond.query.err
+	}
+	if _, err := fmt.Fprintf(w, "%s(%s)", cond.prefix, cond.query.sql); err != nil {
+		return err
+	}
+	w.Append(cond.query.args...)
+	return nil
+}
+
+func (cond *subQueryCond) And(conds ...builder.Cond) builder.Cond {
+	return builder.And(cond, builder.And(conds...))
+}
+
+func (cond *subQueryCond) Or(conds ...builder.Cond) builder.Cond {
+	return builder.Or(cond, builder.Or(conds...))
+}
+
+func (cond *subQueryCond) IsValid() bool {
+	return true
+}
+
+// oracleMaxInItems is the max count of the items of an IN list on Oracle
+const oracleMaxInItems = 1000
+
+// flattenInArgs expands the only slice argument of In to its elements
+func flattenInArgs(args []interface{}) []interface{} {
+	if len(args) != 1 {
+		return args
+	}
+	v := reflect.ValueOf(args[0])
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return args
+	}
+	var flat = make([]interface{}, v.Len())
+	for i := range flat {
+		flat[i] = v.Index(i).Interface()
+	}
+	return flat
+}
+
+// postgresArray is bound as an array literal of Postgres, so that an IN list
+// of any length takes one parameter
+type postgresArray []string
+
+func (a postgresArray) Value() (driver.Value, error) {
+	return "{" + strings.Join(a, ",") + "}", nil
+}
+
+// newPostgresArray formats the elements of the array literal if all the args
+// are integers or strings
+func newPostgresArray(args []interface{}) (postgresArray, bool) {
+	var array = make(postgresArray, len(args))
+	for i, arg := range args {
+		switch v := reflect.ValueOf(arg); v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			array[i] = strconv.FormatInt(v.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			array[i] = strconv.FormatUint(v.Uint(), 10)
+		case reflect.String:
+			array[i] = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(v.String()) + `"`
+		default:
+			return nil, false
+		}
+	}
+	return array, true
+}
+
+// boundParams returns the count of the parameters bound by the statement so
+// far, the parameters added later are checked before executing the SQL
+func (statement *Statement) boundParams() int {
+	_, condArgs, _ := builder.ToSQL(statement.cond)
+	return len(statement.selectArgs(condArgs))
+}
+
+// genIn generates the IN or NOT IN condition. When the args and the other
+// parameters of the statement exceed the max bind parameters of the dialect,
+// the args are bound as one array on Postgres. Otherwise the longer lists
+// are split into several IN lists of the max bind parameters, or of 1000
+// items on Oracle which limits the items of an IN list, and they are OR'd
+// (AND'd for NOT IN). The statement whose parameters still exceed the max
+// fails by ErrTooManyParams before it's executed.
+func (statement *Statement) genIn(quotedCol string, not bool, args []interface{}) builder.Cond {
+	args = flattenInArgs(args)
+
+	var limit = statement.Engine.maxBindParams()
+	var dbType = statement.Engine.dialect.DBType()
+	if dbType == core.POSTGRES && limit > 0 && (len(args) > limit || statement.boundParams()+len(args) > limit) {
+		if array, ok := newPostgresArray(args); ok {
+			if not {
+				return builder.Expr(quotedCol+" <> ALL(?)", array)
+			}
+			return builder.Expr(quotedCol+" = ANY(?)", array)
+		}
+	}
+
+	var chunkSize = limit
+	if dbType == core.ORACLE && (chunkSize <= 0 || chunkSize > oracleMaxInItems) {
+		chunkSize = oracleMaxInItems
+	}
+	if chunkSize <= 0 || len(args) <= chunkSize {
+		return genInList(quotedCol, not, args)
+	}
+
+	var conds = make([]builder.Cond, 0, len(args)/chunkSize+1)
+	for start := 0; start < len(args); start += chunkSize {
+		end := start + chunkSize
+		if end > len(args) {
+			end = len(args)
+		}
+		conds = append(conds, genInList(quotedCol, not, args[start:end]))
+	}
+	if not {
+		return builder.And(conds...)
+	}
+	return builder.Or(conds...)
+}
+
+func genInList(quotedCol string, not bool, args []interface{}) builder.Cond {
+	if not {
+		return builder.NotIn(quotedCol, args...)
+	}
+	return builder.In(quotedCol, args...)
+}
+
 func (statement *Statement) setRefValue(v reflect.Value) error {
 	var err error
 	statement.RefTable, err = statement.Engine.autoMapType(reflect.Indirect(v))
@@ -226,8 +585,16 @@ func (statement *Statement) setRefValue(v reflect.Value) error {
 	return nil
 }
 
-// Table tempororily set table name, the parameter could be a string or a pointer of struct
+// Table tempororily set table name, the parameter could be a string or a pointer of struct.
+// It could also be a *Session or a *builder.Builder as a derived table, which
+// should be named by Alias.
 func (statement *Statement) Table(tableNameOrBean interface{}) *Statement {
+	if query, ok := newSubQuery(tableNameOrBean); ok {
+		statement.derived = query
+		statement.UseCache = false
+		return statement
+	}
+
 	v := rValue(tableNameOrBean)
 	t := v.Type()
 	if t.Kind() == reflect.String {
@@ -249,7 +616,7 @@ func buildUpdates(engine *Engine, table *core.Table, bean interface{},
 	includeVersion bool, includeUpdated bool, includeNil bool,
 	includeAutoIncr bool, allUseBool bool, useAllCols bool,
 	mustColumnMap map[string]bool, nullableMap map[string]bool,
-	columnMap map[string]bool, update, unscoped bool) ([]string, []interface{}) {
+	columnMap map[string]bool, update, unscoped bool) ([]string, []interface{}, error) {
 
 	var colNames = make([]string, 0)
 	var args = make([]interface{}, 0)
@@ -404,8 +771,7 @@ func buildUpdates(engine *Engine, table *core.Table, bean interface{},
 								continue
 							}
 						} else {
-							//TODO: how to handler?
-							panic("not supported")
+							return nil, nil, ErrCompositeCascade
 						}
 					} else {
 						val = fieldValue.Interface()
@@ -415,7 +781,7 @@ func buildUpdates(engine *Engine, table *core.Table, bean interface{},
 					if requiredField || !isStructZero(fieldValue) {
 						bytes, err := json.Marshal(fieldValue.Interface())
 						if err != nil {
-							panic(fmt.Sprintf("mashal %v failed", fieldValue.Interface()))
+							return nil, nil, err
 						}
 						if col.SQLType.IsText() {
 							val = string(bytes)
@@ -484,7 +850,7 @@ func buildUpdates(engine *Engine, table *core.Table, bean interface{},
 		colNames = append(colNames, fmt.Sprintf("%v = ?", engine.Quote(col.Name)))
 	}
 
-	return colNames, args
+	return colNames, args, nil
 }
 
 func (statement *Statement) needTableName() bool {
@@ -502,8 +868,11 @@ func (statement *Statement) colName(col *core.Column, tableName string) string {
 	return statement.Engine.Quote(col.Name)
 }
 
-// TableName return current tableName
+// TableName return current tableName, it's the alias of a derived table
 func (statement *Statement) TableName() string {
+	if statement.derived != nil && statement.TableAlias != "" {
+		return statement.TableAlias
+	}
 	if statement.AltTableName != "" {
 		return statement.AltTableName
 	}
@@ -853,8 +1222,44 @@ func (statement *Statement) genColumnStr() string {
 }
 
 func (statement *Statement) genCreateTableSQL() string {
-	return statement.Engine.dialect.CreateTableSql(statement.RefTable, statement.TableName(),
-		statement.StoreEngine, statement.Charset)
+	engine := statement.Engine
+	table := statement.RefTable
+	tableName := statement.TableName()
+	sqlStr := engine.dialect.CreateTableSql(table, tableName, statement.StoreEngine, statement.Charset)
+
+	// the definitions of the generated columns are rendered by the dialect
+	for _, col := range table.Columns() {
+		if engine.GeneratedColumn(table, col.Name) == nil {
+			continue
+		}
+		defs := []string{col.StringNoPk(engine.dialect), col.String(engine.dialect)}
+		if col.IsPrimaryKey && len(table.PrimaryKeys) == 1 {
+			defs[0], defs[1] = defs[1], defs[0]
+		}
+		for _, def := range defs {
+			if def = strings.TrimSpace(def); strings.Contains(sqlStr, def) {
+				sqlStr = strings.Replace(sqlStr, def, engine.columnSQL(table, col, false), 1)
+				break
+			}
+		}
+	}
+
+	var constraints []string
+	for _, check := range engine.tableChecks(table, tableName) {
+		constraints = append(constraints, engine.checkClause(check))
+	}
+	// sqlite could not add constraints to an existing table, so the foreign
+	// keys are declared in the CREATE TABLE statement
+	if engine.dialect.DBType() == core.SQLITE {
+		for _, fk := range engine.tableForeignKeys(table, tableName) {
+			constraints = append(constraints, engine.foreignKeyClause(fk))
+		}
+	}
+
+	if idx := strings.LastIndex(sqlStr, ")"); idx > -1 && len(constraints) > 0 {
+		sqlStr = sqlStr[:idx] + ", " + strings.Join(constraints, ", ") + sqlStr[idx:]
+	}
+	return sqlStr
 }
 
 func (statement *Statement) genIndexSQL() []string {
@@ -909,10 +1314,15 @@ func (statement *Statement) genDelIndexSQL() []string {
 func (statement *Statement) genAddColumnStr(col *core.Column) (string, []interface{}) {
 	quote := statement.Engine.Quote
 	sql := fmt.Sprintf("ALTER TABLE %v ADD %v", quote(statement.TableName()),
-		col.String(statement.Engine.dialect))
+		statement.Engine.columnSQL(statement.RefTable, col, true))
 	if statement.Engine.dialect.DBType() == core.MYSQL && len(col.Comment) > 0 {
 		sql += " COMMENT '" + col.Comment + "'"
 	}
+	for _, check := range statement.Engine.tableChecks(statement.RefTable, statement.TableName()) {
+		if check.column == col.Name {
+			sql += " " + statement.Engine.checkClause(check)
+		}
+	}
 	sql += ";"
 	return sql, []interface{}{}
 }
@@ -994,7 +1404,7 @@ func (statement *Statement) genGetSQL(bean interface{}) (string, []interface{},
 		return "", nil, err
 	}
 
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	return sqlStr, statement.selectArgs(condArgs), nil
 }
 
 func (statement *Statement) genCountSQL(beans ...interface{}) (string, []interface{}, error) {
@@ -1019,12 +1429,17 @@ func (statement *Statement) genCountSQL(beans ...interface{}) (string, []interfa
 			selectSQL = "count(*)"
 		}
 	}
-	sqlStr, err := statement.genSelectSQL(selectSQL, condSQL)
+	var sqlStr string
+	if len(statement.setOps) > 0 {
+		sqlStr, err = statement.genCompoundSQL(statement.compoundColumnStr(), selectSQL, condSQL)
+	} else {
+		sqlStr, err = statement.genSelectSQL(selectSQL, condSQL)
+	}
 	if err != nil {
 		return "", nil, err
 	}
 
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	return sqlStr, statement.selectArgs(condArgs), nil
 }
 
 func (statement *Statement) genSumSQL(bean interface{}, columns ...string) (string, []interface{}, error) {
@@ -1044,15 +1459,48 @@ func (statement *Statement) genSumSQL(bean interface{}, columns ...string) (stri
 		return "", nil, err
 	}
 
-	sqlStr, err := statement.genSelectSQL(sumSelect, condSQL)
+	var sqlStr string
+	if len(statement.setOps) > 0 {
+		sqlStr, err = statement.genCompoundSQL(statement.compoundColumnStr(), sumSelect, condSQL)
+	} else {
+		sqlStr, err = statement.genSelectSQL(sumSelect, condSQL)
+	}
 	if err != nil {
 		return "", nil, err
 	}
 
-	return sqlStr, append(statement.joinArgs, condArgs...), nil
+	return sqlStr, statement.selectArgs(condArgs), nil
+}
+
+// selectArgs returns the args of the select statement in the order of their
+// placeholders, the common table expressions and the derived table are before
+// the joins and the conditions, and the queries of the set operations are last
+func (statement *Statement) selectArgs(condArgs []interface{}) []interface{} {
+	var args []interface{}
+	for _, cte := range statement.ctes {
+		args = append(args, cte.query.args...)
+	}
+	if statement.derived != nil {
+		args = append(args, statement.derived.args...)
+	}
+	args = append(args, statement.joinArgs...)
+	args = append(args, condArgs...)
+	for _, op := range statement.setOps {
+		args = append(args, op.query.args...)
+	}
+	return args
 }
 
 func (statement *Statement) genSelectSQL(columnStr, condSQL string) (a string, err error) {
+	if len(statement.setOps) > 0 {
+		// the qualified columns of the joined tables are not in the derived table
+		var outerColumnStr = columnStr
+		if strings.Contains(columnStr, ".") {
+			outerColumnStr = "*"
+		}
+		return statement.genCompoundSQL(columnStr, outerColumnStr, condSQL)
+	}
+
 	var distinct string
 	if statement.IsDistinct && !strings.HasPrefix(columnStr, "count") {
 		distinct = "DISTINCT "
@@ -1062,6 +1510,7 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string) (a string, e
 	var quote = statement.Engine.Quote
 	var top string
 	var mssqlCondi string
+	var offsetFetch bool
 
 	if err := statement.processIDParam(); err != nil {
 		return "", err
@@ -1074,7 +1523,12 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string) (a string, e
 	var whereStr = buf.String()
 	var fromStr = " FROM "
 
-	if dialect.DBType() == core.MSSQL && strings.Contains(statement.TableName(), "..") {
+	if statement.derived != nil {
+		if statement.derived.err != nil {
+			return "", statement.derived.err
+		}
+		fromStr += "(" + statement.derived.sql + ")"
+	} else if dialect.DBType() == core.MSSQL && strings.Contains(statement.TableName(), "..") {
 		fromStr += statement.TableName()
 	} else {
 		fromStr += quote(statement.TableName())
@@ -1091,7 +1545,13 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string) (a string, e
 		fromStr = fmt.Sprintf("%v %v", fromStr, statement.JoinStr)
 	}
 
-	if dialect.DBType() == core.MSSQL {
+	if dialect.DBType() == core.MSSQL && statement.Start > 0 &&
+		(len(statement.ctes) > 0 || statement.derived != nil) {
+		// NOT IN (SELECT TOP ...) would repeat the derived table but not the
+		// common table expressions, so the args could not be repeated as
+		// the placeholders, it's paged by OFFSET FETCH instead
+		offsetFetch = true
+	} else if dialect.DBType() == core.MSSQL {
 		if statement.LimitN > 0 {
 			top = fmt.Sprintf(" TOP %d ", statement.LimitN)
 		}
@@ -1149,8 +1609,15 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string) (a string, e
 	}
 	if statement.OrderStr != "" {
 		a = fmt.Sprintf("%v ORDER BY %v", a, statement.OrderStr)
+	} else if offsetFetch {
+		a += " ORDER BY (SELECT NULL)"
 	}
-	if dialect.DBType() != core.MSSQL && dialect.DBType() != core.ORACLE {
+	if offsetFetch {
+		a = fmt.Sprintf("%v OFFSET %d ROWS", a, statement.Start)
+		if statement.LimitN > 0 {
+			a = fmt.Sprintf("%v FETCH NEXT %d ROWS ONLY", a, statement.LimitN)
+		}
+	} else if dialect.DBType() != core.MSSQL && dialect.DBType() != core.ORACLE {
 		if statement.Start > 0 {
 			a = fmt.Sprintf("%v LIMIT %v OFFSET %v", a, statement.LimitN, statement.Start)
 		} else if statement.LimitN > 0 {
@@ -1164,6 +1631,13 @@ func (statement *Statement) genSelectSQL(columnStr, condSQL string) (a string, e
 	if statement.IsForUpdate {
 		a = dialect.ForUpdateSql(a)
 	}
+	if len(statement.ctes) > 0 {
+		with, err := statement.genWithSQL()
+		if err != nil {
+			return "", err
+		}
+		a = with + a
+	}
 
 	return
 }
@@ -1264,3 +1738,273 @@ func (statement *Statement) convertUpdateSQL(sqlStr string) (string, string) {
 		colstrs, statement.Engine.Quote(statement.TableName()),
 		whereStr)
 }
+
+// OnConflict generates an upsert statement on insert, the columns are the
+// unique columns which may conflict, default is the primary keys.
+func (statement *Statement) OnConflict(columns ...string) *Statement {
+	if statement.conflict == nil {
+		statement.conflict = &conflictParam{}
+	}
+	statement.conflict.columns = col2NewCols(columns...)
+	return statement
+}
+
+// DoUpdate updates the columns of the conflicted record, if no columns
+// provided, all the inserted columns except the conflict columns and the
+// created column will be updated.
+func (statement *Statement) DoUpdate(columns ...string) *Statement {
+	if statement.conflict == nil {
+		statement.conflict = &conflictParam{}
+	}
+	statement.conflict.updateCols = col2NewCols(columns...)
+	statement.conflict.doNothing = false
+	return statement
+}
+
+// DoNothing keeps the conflicted record unchanged
+func (statement *Statement) DoNothing() *Statement {
+	if statement.conflict == nil {
+		statement.conflict = &conflictParam{}
+	}
+	statement.conflict.updateCols = nil
+	statement.conflict.doNothing = true
+	return statement
+}
+
+func containsColumn(colNames []string, name string) bool {
+	for _, colName := range colNames {
+		if strings.EqualFold(colName, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// upsertUpdateCols returns the columns which should be updated when the
+// inserted record conflicts. The created column is never updated, while the
+// updated and version columns are always maintained.
+func (statement *Statement) upsertUpdateCols(colNames, conflictCols []string) ([]string, error) {
+	if statement.conflict.doNothing {
+		return nil, nil
+	}
+
+	var table = statement.RefTable
+	var candidates = statement.conflict.updateCols
+	if len(candidates) == 0 {
+		candidates = colNames
+	}
+
+	var updateCols = make([]string, 0, len(candidates))
+	for _, name := range candidates {
+		if !containsColumn(colNames, name) {
+			return nil, fmt.Errorf("column %s to update on conflict is not inserted", name)
+		}
+		if containsColumn(conflictCols, name) {
+			continue
+		}
+		if col := table.GetColumn(name); col != nil && (col.IsCreated || col.IsPrimaryKey || col.IsAutoIncrement) {
+			continue
+		}
+		updateCols = append(updateCols, name)
+	}
+
+	for _, col := range table.Columns() {
+		if (col.IsUpdated || col.IsVersion) && containsColumn(colNames, col.Name) &&
+			!containsColumn(updateCols, col.Name) {
+			updateCols = append(updateCols, col.Name)
+		}
+	}
+	return updateCols, nil
+}
+
+// genUpsertSQL generates an insert statement of the rows which updates or
+// keeps the existing record when it conflicts. MySQL uses ON DUPLICATE KEY
+// UPDATE, Postgres and SQLite use ON CONFLICT, MSSQL and Oracle use MERGE.
+func (statement *Statement) genUpsertSQL(colNames []string, rowPlaces [][]string) (string, error) {
+	if len(colNames) == 0 {
+		return "", errors.New("upsert needs at least one column to insert")
+	}
+
+	var (
+		engine       = statement.Engine
+		quote        = engine.Quote
+		table        = statement.RefTable
+		dbType       = engine.dialect.DBType()
+		tableName    = quote(statement.TableName())
+		conflictCols = statement.conflict.columns
+	)
+	if len(conflictCols) == 0 {
+		conflictCols = table.PrimaryKeys
+	}
+	if len(conflictCols) == 0 && dbType != core.MYSQL {
+		return "", errors.New("upsert needs conflict columns or primary keys")
+	}
+
+	updateCols, err := statement.upsertUpdateCols(colNames, conflictCols)
+	if err != nil {
+		return "", err
+	}
+
+	var quotedCols = make([]string, len(colNames))
+	for i, colName := range colNames {
+		quotedCols[i] = quote(colName)
+	}
+	var isVersion = func(colName string) bool {
+		col := table.GetColumn(colName)
+		return col != nil && col.IsVersion
+	}
+
+	switch dbType {
+	case core.MYSQL:
+		var rows = make([]string, len(rowPlaces))
+		for i, places := range rowPlaces {
+			rows[i] = strings.Join(places, ", ")
+		}
+
+		var sets []string
+		// keep LastInsertId returning the id of the updated record
+		if len(table.AutoIncrement) > 0 {
+			sets = append(sets, fmt.Sprintf("%s = LAST_INSERT_ID(%s)",
+				quote(table.AutoIncrement), quote(table.AutoIncrement)))
+		}
+		for _, colName := range updateCols {
+			if isVersion(colName) {
+				sets = append(sets, fmt.Sprintf("%s = %s + 1", quote(colName), quote(colName)))
+			} else {
+				sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", quote(colName), quote(colName)))
+			}
+		}
+		if len(sets) == 0 {
+			sets = append(sets, fmt.Sprintf("%s = %s", quotedCols[0], quotedCols[0]))
+		}
+
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON DUPLICATE KEY UPDATE %s",
+			tableName, strings.Join(quotedCols, ", "), strings.Join(rows, "), ("),
+			strings.Join(sets, ", ")), nil
+	case core.POSTGRES, core.SQLITE:
+		var rows = make([]string, len(rowPlaces))
+		for i, places := range rowPlaces {
+			rows[i] = strings.Join(places, ", ")
+		}
+
+		var quotedConflicts = make([]string, len(conflictCols))
+		for i, colName := range conflictCols {
+			quotedConflicts[i] = quote(colName)
+		}
+
+		var sqlStr = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s)",
+			tableName, strings.Join(quotedCols, ", "), strings.Join(rows, "), ("),
+			strings.Join(quotedConflicts, ", "))
+		if len(updateCols) == 0 {
+			return sqlStr + " DO NOTHING", nil
+		}
+
+		var sets = make([]string, len(updateCols))
+		for i, colName := range updateCols {
+			if isVersion(colName) {
+				sets[i] = fmt.Sprintf("%s = %s.%s + 1", quote(colName), tableName, quote(colName))
+			} else {
+				sets[i] = fmt.Sprintf("%s = excluded.%s", quote(colName), quote(colName))
+			}
+		}
+		return sqlStr + " DO UPDATE SET " + strings.Join(sets, ", "), nil
+	case core.MSSQL, core.ORACLE:
+		var buf bytes.Buffer
+		if dbType == core.MSSQL {
+			var rows = make([]string, len(rowPlaces))
+			for i, places := range rowPlaces {
+				rows[i] = strings.Join(places, ", ")
+			}
+			fmt.Fprintf(&buf, "MERGE INTO %s AS target USING (VALUES (%s)) AS source (%s)",
+				tableName, strings.Join(rows, "), ("), strings.Join(quotedCols, ", "))
+		} else {
+			var rows = make([]string, len(rowPlaces))
+			for i, places := range rowPlaces {
+				var values = make([]string, len(places))
+				for j, place := range places {
+					values[j] = place + " " + quotedCols[j]
+				}
+				rows[i] = "SELECT " + strings.Join(values, ", ") + " FROM DUAL"
+			}
+			fmt.Fprintf(&buf, "MERGE INTO %s target USING (%s) source",
+				tableName, strings.Join(rows, " UNION ALL "))
+		}
+
+		var ons = make([]string, len(conflictCols))
+		for i, colName := range conflictCols {
+			ons[i] = fmt.Sprintf("target.%s = source.%s", quote(colName), quote(colName))
+		}
+		fmt.Fprintf(&buf, " ON (%s)", strings.Join(ons, " AND "))
+
+		if len(updateCols) > 0 {
+			var sets = make([]string, len(updateCols))
+			for i, colName := range updateCols {
+				if isVersion(colName) {
+					sets[i] = fmt.Sprintf("target.%s = target.%s + 1", quote(colName), quote(colName))
+				} else {
+					sets[i] = fmt.Sprintf("target.%s = source.%s", quote(colName), quote(colName))
+				}
+			}
+			fmt.Fprintf(&buf, " WHEN MATCHED THEN UPDATE SET %s", strings.Join(sets, ", "))
+		}
+
+		var sourceCols = make([]string, len(colNames))
+		for i, colName := range colNames {
+			sourceCols[i] = "source." + quote(colName)
+		}
+		fmt.Fprintf(&buf, " WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)",
+			strings.Join(quotedCols, ", "), strings.Join(sourceCols, ", "))
+		if dbType == core.MSSQL {
+			// MERGE must be terminated by a semicolon on MSSQL
+			buf.WriteString(";")
+		}
+		return buf.String(), nil
+	}
+	return "", ErrNotImplemented
+}
+
+// Returning returns the columns of the inserted, updated or deleted records
+func (statement *Statement) Returning(columns ...string) *Statement {
+	statement.returning = append(statement.returning, col2NewCols(columns...)...)
+	return statement
+}
+
+// genReturning generates the clause which returns the affected records, the
+// OUTPUT clause for MSSQL which should follow the column list or the SET
+// clause, and the RETURNING clause for Postgres and SQLite which ends the
+// statement. pseudoTable is INSERTED or DELETED for the OUTPUT clause.
+func (statement *Statement) genReturning(pseudoTable string) (output, returning string, err error) {
+	if len(statement.returning) == 0 {
+		return "", "", nil
+	}
+
+	var dbType = statement.Engine.dialect.DBType()
+	var prefix string
+	switch dbType {
+	case core.POSTGRES, core.SQLITE:
+	case core.MSSQL:
+		prefix = pseudoTable + "."
+	default:
+		return "", "", fmt.Errorf("%w by %v", ErrReturningUnsupported, dbType)
+	}
+
+	var columns = make([]string, len(statement.returning))
+	for i, colName := range statement.returning {
+		if colName == "*" {
+			columns[i] = prefix + colName
+		} else {
+			columns[i] = prefix + statement.Engine.Quote(colName)
+		}
+	}
+
+	if dbType == core.MSSQL {
+		return " OUTPUT " + strings.Join(columns, ", "), "", nil
+	}
+	return "", " RETURNING " + strings.Join(columns, ", "), nil
+}
+
+// Preload loads the relations of the found beans
+func (statement *Statement) Preload(relations ...string) *Statement {
+	statement.preloads = append(statement.preloads, relations...)
+	return statement
+}
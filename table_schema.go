@@ -0,0 +1,173 @@
+// Copyright 2017 The Xorm Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package xorm
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lingochamp/core"
+)
+
+// CheckConstraint represents a CHECK constraint of a table
+type CheckConstraint struct {
+	Name string
+	Expr string
+
+	column string
+}
+
+// GeneratedColumn represents the expression of a generated column, which is
+// a computed column of MSSQL
+type GeneratedColumn struct {
+	Expr   string
+	Stored bool
+}
+
+// structSchema is the schema declared by the tags of a struct which could not
+// be kept by core.Table
+type structSchema struct {
+	foreignKeys []*ForeignKey
+	checks      []*CheckConstraint
+	generated   map[string]*GeneratedColumn
+	// the new column names to the old ones
+	renames map[string]string
+}
+
+func newStructSchema() *structSchema {
+	return &structSchema{
+		generated: make(map[string]*GeneratedColumn),
+		renames:   make(map[string]string),
+	}
+}
+
+func (schema *structSchema) merge(src *structSchema) {
+	if src == nil {
+		return
+	}
+	schema.foreignKeys = append(schema.foreignKeys, src.foreignKeys...)
+	schema.checks = append(schema.checks, src.checks...)
+	for name, gen := range src.generated {
+		schema.generated[name] = gen
+	}
+	for name, oldName := range src.renames {
+		schema.renames[name] = oldName
+	}
+}
+
+// generatedColumnDialect is implemented by the dialects which support
+// generated columns
+type generatedColumnDialect interface {
+	GeneratedColumnSql(col *core.Column, gen *GeneratedColumn) string
+}
+
+func (engine *Engine) setStructSchema(t reflect.Type, schema *structSchema) {
+	engine.schemaMutex.Lock()
+	engine.structSchemas[t] = schema
+	engine.schemaMutex.Unlock()
+}
+
+// structSchemaOf returns the schema declared by the tags of the struct, the
+// names of the constraints are empty until they are added to a table
+func (engine *Engine) structSchemaOf(t reflect.Type) *structSchema {
+	engine.schemaMutex.RLock()
+	defer engine.schemaMutex.RUnlock()
+	if schema, ok := engine.structSchemas[t]; ok {
+		return schema
+	}
+	return newStructSchema()
+}
+
+func checkName(tableName, colName string) string {
+	return fmt.Sprintf("CHK_%v_%v", tableName, colName)
+}
+
+// Checks returns the check constraints declared by the tags of the struct
+// which the table is mapped from
+func (engine *Engine) Checks(table *core.Table) []*CheckConstraint {
+	return engine.tableChecks(table, table.Name)
+}
+
+// tableChecks returns the copies of the check constraints of the table, which
+// are named by the table name if the name is empty
+func (engine *Engine) tableChecks(table *core.Table, tableName string) []*CheckConstraint {
+	if table.Type == nil {
+		return nil
+	}
+
+	checks := engine.structSchemaOf(table.Type).checks
+	var res = make([]*CheckConstraint, 0, len(checks))
+	for _, check := range checks {
+		checkCopy := *check
+		if checkCopy.Name == "" {
+			checkCopy.Name = checkName(tableName, check.column)
+		}
+		res = append(res, &checkCopy)
+	}
+	return res
+}
+
+// GeneratedColumn returns the expression of the column if it's a generated
+// column of the struct which the table is mapped from, or nil
+func (engine *Engine) GeneratedColumn(table *core.Table, colName string) *GeneratedColumn {
+	if table.Type == nil {
+		return nil
+	}
+	return engine.structSchemaOf(table.Type).generated[colName]
+}
+
+// RenamedFrom returns the old name of the column declared by the renamed_from
+// tag of the struct which the table is mapped from, or an empty string
+func (engine *Engine) RenamedFrom(table *core.Table, colName string) string {
+	if table.Type == nil {
+		return ""
+	}
+	return engine.structSchemaOf(table.Type).renames[colName]
+}
+
+// renameColumnDialect is implemented by the dialects which have no RENAME
+// COLUMN statement
+type renameColumnDialect interface {
+	RenameColumnSql(tableName, oldName string, col *core.Column) string
+}
+
+func (engine *Engine) renameColumnSQL(tableName, oldName string, col *core.Column) string {
+	if dialect, ok := engine.dialect.(renameColumnDialect); ok {
+		return dialect.RenameColumnSql(tableName, oldName, col)
+	}
+	return fmt.Sprintf("ALTER TABLE %v RENAME COLUMN %v TO %v",
+		engine.Quote(tableName), engine.Quote(oldName), engine.Quote(col.Name))
+}
+
+// dropColumnDialect is implemented by the dialects which need more statements
+// to drop a column
+type dropColumnDialect interface {
+	DropColumnSqls(tableName, colName string) []string
+}
+
+func (engine *Engine) dropColumnSQLs(tableName, colName string) []string {
+	if dialect, ok := engine.dialect.(dropColumnDialect); ok {
+		return dialect.DropColumnSqls(tableName, colName)
+	}
+	return []string{fmt.Sprintf("ALTER TABLE %v DROP COLUMN %v", engine.Quote(tableName), engine.Quote(colName))}
+}
+
+func (engine *Engine) checkClause(check *CheckConstraint) string {
+	return fmt.Sprintf("CONSTRAINT %v CHECK (%v)", engine.Quote(check.Name), check.Expr)
+}
+
+// columnSQL returns the definition of the column in CREATE TABLE and ADD
+// COLUMN statements, isPk is true if it's the only primary key
+func (engine *Engine) columnSQL(table *core.Table, col *core.Column, isPk bool) string {
+	if gen := engine.GeneratedColumn(table, col.Name); gen != nil {
+		if dialect, ok := engine.dialect.(generatedColumnDialect); ok {
+			return dialect.GeneratedColumnSql(col, gen)
+		}
+	}
+	if isPk {
+		return col.String(engine.dialect)
+	}
+	return col.StringNoPk(engine.dialect)
+}
@@ -5,6 +5,7 @@
 package xorm
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
@@ -28,6 +29,12 @@ type tagContext struct {
 	hasCacheTag     bool
 	hasNoCacheTag   bool
 	ignoreNext      bool
+	isRelation      bool
+	foreignKey      *ForeignKey
+	check           string
+	generated       *GeneratedColumn
+	renamedFrom     string
+	extendedSchema  *structSchema
 }
 
 // tagHandler describes tag handler for XORM
@@ -36,25 +43,34 @@ type tagHandler func(ctx *tagContext) error
 var (
 	// defaultTagHandlers enumerates all the default tag handler
 	defaultTagHandlers = map[string]tagHandler{
-		"<-":       OnlyFromDBTagHandler,
-		"->":       OnlyToDBTagHandler,
-		"PK":       PKTagHandler,
-		"NULL":     NULLTagHandler,
-		"NOT":      IgnoreTagHandler,
-		"AUTOINCR": AutoIncrTagHandler,
-		"DEFAULT":  DefaultTagHandler,
-		"CREATED":  CreatedTagHandler,
-		"UPDATED":  UpdatedTagHandler,
-		"DELETED":  DeletedTagHandler,
-		"VERSION":  VersionTagHandler,
-		"UTC":      UTCTagHandler,
-		"LOCAL":    LocalTagHandler,
-		"NOTNULL":  NotNullTagHandler,
-		"INDEX":    IndexTagHandler,
-		"UNIQUE":   UniqueTagHandler,
-		"CACHE":    CacheTagHandler,
-		"NOCACHE":  NoCacheTagHandler,
-		"COMMENT":  CommentTagHandler,
+		"<-":        OnlyFromDBTagHandler,
+		"->":        OnlyToDBTagHandler,
+		"PK":        PKTagHandler,
+		"NULL":      NULLTagHandler,
+		"NOT":       IgnoreTagHandler,
+		"AUTOINCR":  AutoIncrTagHandler,
+		"DEFAULT":   DefaultTagHandler,
+		"CREATED":   CreatedTagHandler,
+		"UPDATED":   UpdatedTagHandler,
+		"DELETED":   DeletedTagHandler,
+		"VERSION":   VersionTagHandler,
+		"UTC":       UTCTagHandler,
+		"LOCAL":     LocalTagHandler,
+		"NOTNULL":   NotNullTagHandler,
+		"INDEX":     IndexTagHandler,
+		"UNIQUE":    UniqueTagHandler,
+		"CACHE":     CacheTagHandler,
+		"NOCACHE":   NoCacheTagHandler,
+		"COMMENT":   CommentTagHandler,
+		"HAS_MANY":  RelationTagHandler,
+		"MANY2MANY": RelationTagHandler,
+		"FK":        ForeignKeyTagHandler,
+		"ON_DELETE": OnDeleteTagHandler,
+		"ON_UPDATE": OnUpdateTagHandler,
+		"CHECK":     CheckTagHandler,
+		"GENERATED": GeneratedTagHandler,
+
+		"RENAMED_FROM": RenamedFromTagHandler,
 	}
 )
 
@@ -241,6 +257,102 @@ func SQLTypeTagHandler(ctx *tagContext) error {
 	return nil
 }
 
+// ForeignKeyTagHandler describes fk tag handler, fk(users.id) means the
+// column refers to the column id of the table users, the referenced column
+// is id if it's omitted
+func ForeignKeyTagHandler(ctx *tagContext) error {
+	if len(ctx.params) != 1 || strings.TrimSpace(ctx.params[0]) == "" {
+		return errors.New("fk tag needs the referenced table, e.g. fk(users.id)")
+	}
+	if ctx.foreignKey == nil {
+		ctx.foreignKey = new(ForeignKey)
+	}
+
+	ref := strings.TrimSpace(ctx.params[0])
+	if idx := strings.LastIndex(ref, "."); idx > -1 {
+		ctx.foreignKey.RefTable = ref[:idx]
+		ctx.foreignKey.RefColumns = []string{ref[idx+1:]}
+	} else {
+		ctx.foreignKey.RefTable = ref
+		ctx.foreignKey.RefColumns = []string{"id"}
+	}
+	return nil
+}
+
+func foreignKeyActionParam(ctx *tagContext) (string, error) {
+	if len(ctx.params) != 1 {
+		return "", fmt.Errorf("%s tag needs a referential action, e.g. %s(cascade)",
+			strings.ToLower(ctx.tagName), strings.ToLower(ctx.tagName))
+	}
+	if ctx.foreignKey == nil {
+		ctx.foreignKey = new(ForeignKey)
+	}
+	return foreignKeyAction(ctx.params[0])
+}
+
+// OnDeleteTagHandler describes on_delete tag handler of the foreign key, the
+// words of the action are separated by underscores, e.g. on_delete(set_null)
+func OnDeleteTagHandler(ctx *tagContext) error {
+	action, err := foreignKeyActionParam(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.foreignKey.OnDelete = action
+	return nil
+}
+
+// OnUpdateTagHandler describes on_update tag handler of the foreign key
+func OnUpdateTagHandler(ctx *tagContext) error {
+	action, err := foreignKeyActionParam(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.foreignKey.OnUpdate = action
+	return nil
+}
+
+// CheckTagHandler describes check tag handler, check(age>=0) adds a CHECK
+// constraint of the expression to the table
+func CheckTagHandler(ctx *tagContext) error {
+	expr := strings.TrimSpace(strings.Join(ctx.params, ","))
+	if expr == "" {
+		return errors.New("check tag needs an expression, e.g. check(age>=0)")
+	}
+	ctx.check = expr
+	return nil
+}
+
+// GeneratedTagHandler describes generated tag handler, generated(price*qty)
+// is a virtual column and generated(price*qty) stored is a stored column.
+// The column is read only as the <- tag.
+func GeneratedTagHandler(ctx *tagContext) error {
+	expr := strings.TrimSpace(strings.Join(ctx.params, ","))
+	if expr == "" {
+		return errors.New("generated tag needs an expression, e.g. generated(price*qty)")
+	}
+
+	ctx.generated = &GeneratedColumn{Expr: expr}
+	switch strings.ToUpper(ctx.nextTag) {
+	case "STORED":
+		ctx.generated.Stored = true
+		ctx.ignoreNext = true
+	case "VIRTUAL":
+		ctx.ignoreNext = true
+	}
+	ctx.col.MapType = core.ONLYFROMDB
+	return nil
+}
+
+// RenamedFromTagHandler describes renamed_from tag handler, renamed_from(name)
+// makes Sync2 rename the column name of the table to the column of the field
+func RenamedFromTagHandler(ctx *tagContext) error {
+	if len(ctx.params) != 1 {
+		return errors.New("renamed_from tag needs the old column name, e.g. renamed_from(name)")
+	}
+	ctx.renamedFrom = strings.Trim(strings.TrimSpace(ctx.params[0]), "'\"`")
+	return nil
+}
+
 // ExtendsTagHandler describes extends tag handler
 func ExtendsTagHandler(ctx *tagContext) error {
 	var fieldValue = ctx.fieldValue
@@ -267,6 +379,7 @@ func ExtendsTagHandler(ctx *tagContext) error {
 				addIndex(indexName, ctx.table, col, indexType)
 			}
 		}
+		ctx.extendedSchema = ctx.engine.structSchemaOf(parentTable.Type)
 	default:
 		//TODO: warning
 	}
@@ -288,3 +401,10 @@ func NoCacheTagHandler(ctx *tagContext) error {
 	}
 	return nil
 }
+
+// RelationTagHandler describes has_many and many2many tag handler, the field
+// is not a column but a relation loaded by Preload
+func RelationTagHandler(ctx *tagContext) error {
+	ctx.isRelation = true
+	return nil
+}
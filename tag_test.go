@@ -394,3 +394,77 @@ func TestTagTime(t *testing.T) {
 	assert.EqualValues(t, s.Created.UTC().Format("2006-01-02 15:04:05"),
 		strings.Replace(strings.Replace(tm, "T", " ", -1), "Z", "", -1))
 }
+
+func TestTagForeignKey(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type TagForeignKey struct {
+		Id       int64
+		UserId   int64 `xorm:"'user_id' fk(user) on_delete(set_null) on_update(cascade)"`
+		AuthorId int64 `xorm:"'writer_id' fk(users.uid)"`
+	}
+
+	table := testEngine.TableInfo(new(TagForeignKey))
+	fks := testEngine.ForeignKeys(table.Table)
+	assert.EqualValues(t, 2, len(fks))
+	assert.EqualValues(t, &ForeignKey{
+		Name:       "FK_" + table.Name + "_user_id",
+		Columns:    []string{"user_id"},
+		RefTable:   "user",
+		RefColumns: []string{"id"},
+		OnDelete:   "SET NULL",
+		OnUpdate:   "CASCADE",
+	}, fks[0])
+	assert.EqualValues(t, []string{"writer_id"}, fks[1].Columns)
+	assert.EqualValues(t, "users", fks[1].RefTable)
+	assert.EqualValues(t, []string{"uid"}, fks[1].RefColumns)
+
+	type TagForeignKeyNoRef struct {
+		Id     int64
+		UserId int64 `xorm:"on_delete(cascade)"`
+	}
+	assert.Error(t, testEngine.Sync2(context.Background(), new(TagForeignKeyNoRef)))
+}
+
+func TestTagCheckAndGenerated(t *testing.T) {
+	assert.NoError(t, prepareEngine())
+
+	type TagCheckGenerated struct {
+		Id    int64
+		Price int `xorm:"'price' check(price >= 0)"`
+		Qty   int `xorm:"'qty'"`
+		Total int `xorm:"'total' generated(price * qty) stored"`
+	}
+
+	assert.NoError(t, testEngine.Sync2(context.Background(), new(TagCheckGenerated)))
+
+	table := testEngine.TableInfo(new(TagCheckGenerated))
+	checks := testEngine.Checks(table.Table)
+	if assert.EqualValues(t, 1, len(checks)) {
+		assert.EqualValues(t, "price >= 0", checks[0].Expr)
+	}
+
+	var bean = TagCheckGenerated{Price: 3, Qty: 4, Total: 1}
+	cnt, err := testEngine.Insert(context.Background(), &bean)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	var got TagCheckGenerated
+	has, err := testEngine.ID(bean.Id).Get(context.Background(), &got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 12, got.Total)
+
+	bean.Qty = 5
+	cnt, err = testEngine.ID(bean.Id).Update(context.Background(), &bean)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 1, cnt)
+
+	has, err = testEngine.ID(bean.Id).Get(context.Background(), &got)
+	assert.NoError(t, err)
+	assert.True(t, has)
+	assert.EqualValues(t, 15, got.Total)
+
+	_, err = testEngine.Insert(context.Background(), &TagCheckGenerated{Price: -1, Qty: 1})
+	assert.Error(t, err)
+}
@@ -86,6 +86,9 @@ func NewEngine(driverName string, dataSourceName string, opts ...Option) (*Engin
 		dialect:       dialect,
 		Tables:        make(map[reflect.Type]*core.Table, 0),
 		mutex:         &sync.RWMutex{},
+		schemaMutex:   &sync.RWMutex{},
+		structSchemas: make(map[reflect.Type]*structSchema),
+		dbForeignKeys: make(map[string][]*ForeignKey),
 		TagIdentifier: "xorm",
 		tagHandlers:   defaultTagHandlers,
 		opts:          opts,